@@ -0,0 +1,272 @@
+package helperfuncs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/config"
+	apimodels "github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/models/api-models"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ThreadWindowSize is how many of a chat's newest stored messages are loaded to
+// work out its active branch.
+const ThreadWindowSize = 60
+
+// ///////////////////// BRANCH HELPER FUNCS ////////////////////////
+
+// parentIds resolves the parent of every message in a window. Messages stored
+// before branching existed carry no ParentId and follow the message before them.
+func parentIds(messages []apimodels.Message) []string {
+	parents := make([]string, len(messages))
+	for i, m := range messages {
+		switch {
+		case m.ParentId != "":
+			parents[i] = m.ParentId
+		case i > 0:
+			parents[i] = messages[i-1].MsgID
+		default:
+			parents[i] = apimodels.ThreadRoot
+		}
+	}
+	return parents
+}
+
+// pathTo walks from messages[i] back through its parents while they are in the
+// window and returns the path oldest first.
+func pathTo(messages []apimodels.Message, parents []string, index map[string]int, i int) []apimodels.Message {
+	var path []apimodels.Message
+	for i >= 0 {
+		path = append(path, messages[i])
+		p, ok := index[parents[i]]
+		if !ok || p >= i {
+			break
+		}
+		i = p
+	}
+	slices.Reverse(path)
+	return path
+}
+
+func indexById(messages []apimodels.Message) map[string]int {
+	index := make(map[string]int, len(messages))
+	for i, m := range messages {
+		index[m.MsgID] = i
+	}
+	return index
+}
+
+// ActiveBranch returns the path through a chat's variants that the conversation
+// currently follows, oldest first. A message is on it when neither it nor any of
+// its ancestors in the window is Inactive; parents older than the window count as active.
+func ActiveBranch(messages []apimodels.Message) []apimodels.Message {
+	parents := parentIds(messages)
+	index := make(map[string]int, len(messages))
+	active := make([]bool, len(messages))
+	leaf := -1
+	for i, m := range messages {
+		p, inWindow := index[parents[i]]
+		active[i] = !m.Inactive && (!inWindow || active[p])
+		index[m.MsgID] = i
+		if active[i] {
+			leaf = i
+		}
+	}
+	if leaf < 0 {
+		return nil
+	}
+	return pathTo(messages, parents, index, leaf)
+}
+
+// ThreadPath returns the messages leading up to and including msgId, oldest first,
+// or nil if msgId is not in the window.
+func ThreadPath(messages []apimodels.Message, msgId string) []apimodels.Message {
+	index := indexById(messages)
+	i, ok := index[msgId]
+	if !ok {
+		return nil
+	}
+	return pathTo(messages, parentIds(messages), index, i)
+}
+
+// FindMessage returns a message in the window along with the msgId of its parent.
+func FindMessage(messages []apimodels.Message, msgId string) (apimodels.Message, string, bool) {
+	i, ok := indexById(messages)[msgId]
+	if !ok {
+		return apimodels.Message{}, "", false
+	}
+	return messages[i], parentIds(messages)[i], true
+}
+
+// SelectVariant returns the flag changes, keyed by msgId with the new Inactive
+// value, that put msgId on the active branch: it and each of its ancestors become
+// the active variant among their siblings.
+func SelectVariant(messages []apimodels.Message, msgId string) map[string]bool {
+	parents := parentIds(messages)
+	index := indexById(messages)
+	desired := map[string]bool{}
+	for _, m := range ThreadPath(messages, msgId) {
+		parent := parents[index[m.MsgID]]
+		for j, sibling := range messages {
+			if parents[j] == parent {
+				desired[sibling.MsgID] = sibling.MsgID != m.MsgID
+			}
+		}
+	}
+	return diffFlags(messages, desired)
+}
+
+// BranchFrom returns the flag changes that make parentId the tip of the active
+// branch, so that a message attached under it becomes the only active variant.
+func BranchFrom(messages []apimodels.Message, parentId string) map[string]bool {
+	desired := map[string]bool{}
+	if parentId != apimodels.ThreadRoot {
+		for id, inactive := range SelectVariant(messages, parentId) {
+			desired[id] = inactive
+		}
+	}
+	for j, p := range parentIds(messages) {
+		if p == parentId {
+			desired[messages[j].MsgID] = true
+		}
+	}
+	return diffFlags(messages, desired)
+}
+
+// diffFlags drops the entries of desired that already match the window.
+func diffFlags(messages []apimodels.Message, desired map[string]bool) map[string]bool {
+	changes := map[string]bool{}
+	for _, m := range messages {
+		if inactive, ok := desired[m.MsgID]; ok && inactive != m.Inactive {
+			changes[m.MsgID] = inactive
+		}
+	}
+	return changes
+}
+
+// ApplyFlagChanges sets the Inactive flags in an in-memory window.
+func ApplyFlagChanges(messages []apimodels.Message, changes map[string]bool) {
+	for i := range messages {
+		if inactive, ok := changes[messages[i].MsgID]; ok {
+			messages[i].Inactive = inactive
+		}
+	}
+}
+
+// ///////////////////// BRANCH STORAGE HELPER FUNCS ////////////////////////
+
+// LoadThreadWindow returns a chat's summary and its newest messages, merging what is
+// stored in Mongo with what is still waiting in Redis for a flush.
+func LoadThreadWindow(ctx context.Context, userId, chatId string) (string, []apimodels.Message, error) {
+	key := fmt.Sprintf("chats:%s:%s", userId, chatId)
+
+	summary, pending, err := FetchChatsFromRedis(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	stored, err := FetchLastMessages(ctx, userId, chatId, ThreadWindowSize)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if summary == "" {
+		dbSummary, err := FetchChatSummary(ctx, userId, chatId)
+		if err != nil {
+			return "", nil, err
+		}
+		summary = dbSummary
+		UpdateSummaryInRedis(ctx, key, summary)
+	}
+
+	// A message can be in both while a flush is in progress; Redis holds the newer copy
+	index := indexById(stored)
+	for _, m := range pending {
+		if i, ok := index[m.MsgID]; ok {
+			stored[i] = m
+			continue
+		}
+		stored = append(stored, m)
+	}
+	return summary, stored, nil
+}
+
+// PersistFlagChanges writes Inactive flag changes to the chat's pending messages in
+// Redis and to its stored messages in Mongo.
+func PersistFlagChanges(ctx context.Context, userId, chatId string, changes map[string]bool) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	key := fmt.Sprintf("chats:%s:%s", userId, chatId)
+
+	// Watched so a flush clearing the hash at the same time is not undone; the flush
+	// copies the flags set here to Mongo for the messages it stored
+	update := func(tx *redis.Tx) error {
+		raw, err := tx.HGet(ctx, key, "messages").Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil
+			}
+			return err
+		}
+		var pending []apimodels.Message
+		if raw != "" {
+			if err := json.Unmarshal([]byte(raw), &pending); err != nil {
+				return err
+			}
+		}
+		dirty := false
+		for i := range pending {
+			if inactive, ok := changes[pending[i].MsgID]; ok {
+				pending[i].Inactive = inactive
+				dirty = true
+			}
+		}
+		if !dirty {
+			return nil
+		}
+		data, _ := json.Marshal(pending)
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, "messages", data)
+			return nil
+		})
+		return err
+	}
+	var err error = redis.TxFailedErr
+	for attempt := 0; attempt < 5 && errors.Is(err, redis.TxFailedErr); attempt++ {
+		err = config.RedisClient.Watch(ctx, update, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	var deactivate, activate []string
+	for id, inactive := range changes {
+		if inactive {
+			deactivate = append(deactivate, id)
+		} else {
+			activate = append(activate, id)
+		}
+	}
+
+	collection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+	filter := bson.M{"userId": userId, "chatId": chatId}
+	if len(deactivate) > 0 {
+		filter["msgId"] = bson.M{"$in": deactivate}
+		if _, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"inactive": true}}); err != nil {
+			return err
+		}
+	}
+	if len(activate) > 0 {
+		filter["msgId"] = bson.M{"$in": activate}
+		if _, err := collection.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"inactive": ""}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package helperfuncs
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	apimodels "github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/models/api-models"
+)
+
+func msg(id, parent string, inactive bool) apimodels.Message {
+	return apimodels.Message{MsgID: id, ParentId: parent, Inactive: inactive}
+}
+
+func msgIds(messages []apimodels.Message) []string {
+	var ids []string
+	for _, m := range messages {
+		ids = append(ids, m.MsgID)
+	}
+	return ids
+}
+
+func TestActiveBranch(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []apimodels.Message
+		want     []string
+	}{
+		{
+			name:     "legacy messages follow the one before them",
+			messages: []apimodels.Message{msg("u1", "", false), msg("a1", "", false), msg("u2", "", false), msg("a2", "", false)},
+			want:     []string{"u1", "a1", "u2", "a2"},
+		},
+		{
+			name: "branched messages after legacy ones",
+			messages: []apimodels.Message{
+				msg("u1", "", false), msg("a1", "", false),
+				msg("u2", "a1", false), msg("a2", "u2", true), msg("a2b", "u2", false),
+			},
+			want: []string{"u1", "a1", "u2", "a2b"},
+		},
+		{
+			name: "window starts mid-chat",
+			messages: []apimodels.Message{
+				msg("u5", "a4", false), msg("a5", "u5", false), msg("u6", "a5", false), msg("a6", "u6", false),
+			},
+			want: []string{"u5", "a5", "u6", "a6"},
+		},
+		{
+			name: "window starts mid-chat with legacy messages",
+			messages: []apimodels.Message{
+				msg("a4", "", false), msg("u5", "", false), msg("a5", "", false),
+			},
+			want: []string{"a4", "u5", "a5"},
+		},
+		{
+			name: "edit of the first message",
+			messages: []apimodels.Message{
+				msg("u1", apimodels.ThreadRoot, true), msg("a1", "u1", false),
+				msg("u1b", apimodels.ThreadRoot, false), msg("a1b", "u1b", false),
+			},
+			want: []string{"u1b", "a1b"},
+		},
+		{
+			name: "regenerated reply",
+			messages: []apimodels.Message{
+				msg("u1", apimodels.ThreadRoot, false), msg("a1", "u1", true), msg("a1b", "u1", false),
+			},
+			want: []string{"u1", "a1b"},
+		},
+		{
+			name:     "nothing active",
+			messages: []apimodels.Message{msg("u1", apimodels.ThreadRoot, true), msg("a1", "u1", false)},
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := msgIds(ActiveBranch(tt.messages)); !slices.Equal(got, tt.want) {
+				t.Errorf("ActiveBranch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreadPath(t *testing.T) {
+	messages := []apimodels.Message{
+		msg("u1", "", false), msg("a1", "", false),
+		msg("u2", "a1", true), msg("a2", "u2", false),
+		msg("u2b", "a1", false), msg("a2b", "u2b", true), msg("a2c", "u2b", false),
+	}
+	window := []apimodels.Message{msg("u5", "a4", false), msg("a5", "u5", false)}
+
+	tests := []struct {
+		name     string
+		messages []apimodels.Message
+		msgId    string
+		want     []string
+	}{
+		{name: "first legacy message", messages: messages, msgId: "u1", want: []string{"u1"}},
+		{name: "legacy parent", messages: messages, msgId: "a1", want: []string{"u1", "a1"}},
+		{name: "inactive edit", messages: messages, msgId: "a2", want: []string{"u1", "a1", "u2", "a2"}},
+		{name: "inactive regenerated reply", messages: messages, msgId: "a2b", want: []string{"u1", "a1", "u2b", "a2b"}},
+		{name: "window starts mid-chat", messages: window, msgId: "a5", want: []string{"u5", "a5"}},
+		{name: "not in window", messages: window, msgId: "a4", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := msgIds(ThreadPath(tt.messages, tt.msgId)); !slices.Equal(got, tt.want) {
+				t.Errorf("ThreadPath(%q) = %v, want %v", tt.msgId, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindMessage(t *testing.T) {
+	messages := []apimodels.Message{
+		msg("u1", "", false), msg("a1", "", false), msg("a1b", "u1", false),
+	}
+
+	tests := []struct {
+		msgId      string
+		wantParent string
+		wantFound  bool
+	}{
+		{msgId: "u1", wantParent: apimodels.ThreadRoot, wantFound: true},
+		{msgId: "a1", wantParent: "u1", wantFound: true},
+		{msgId: "a1b", wantParent: "u1", wantFound: true},
+		{msgId: "missing", wantParent: "", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msgId, func(t *testing.T) {
+			m, parent, found := FindMessage(messages, tt.msgId)
+			if found != tt.wantFound || parent != tt.wantParent {
+				t.Errorf("FindMessage(%q) = (%q, %v), want (%q, %v)", tt.msgId, parent, found, tt.wantParent, tt.wantFound)
+			}
+			if found && m.MsgID != tt.msgId {
+				t.Errorf("FindMessage(%q) returned %q", tt.msgId, m.MsgID)
+			}
+		})
+	}
+}
+
+func TestSelectVariant(t *testing.T) {
+	tests := []struct {
+		name       string
+		messages   []apimodels.Message
+		msgId      string
+		want       map[string]bool
+		wantBranch []string
+	}{
+		{
+			name: "older edit of the first message",
+			messages: []apimodels.Message{
+				msg("u1", apimodels.ThreadRoot, true), msg("a1", "u1", false),
+				msg("u1b", apimodels.ThreadRoot, false), msg("a1b", "u1b", false),
+			},
+			msgId:      "a1",
+			want:       map[string]bool{"u1": false, "u1b": true},
+			wantBranch: []string{"u1", "a1"},
+		},
+		{
+			name: "older regenerated reply",
+			messages: []apimodels.Message{
+				msg("u1", "", false), msg("a1", "", true), msg("a1b", "u1", false),
+			},
+			msgId:      "a1",
+			want:       map[string]bool{"a1": false, "a1b": true},
+			wantBranch: []string{"u1", "a1"},
+		},
+		{
+			name: "reply under an inactive edit",
+			messages: []apimodels.Message{
+				msg("u1", apimodels.ThreadRoot, false), msg("a1", "u1", false),
+				msg("u2", "a1", true), msg("a2", "u2", true), msg("a2b", "u2", false),
+				msg("u2b", "a1", false), msg("a2c", "u2b", false),
+			},
+			msgId:      "a2",
+			want:       map[string]bool{"u2": false, "u2b": true, "a2": false, "a2b": true},
+			wantBranch: []string{"u1", "a1", "u2", "a2"},
+		},
+		{
+			name: "already active",
+			messages: []apimodels.Message{
+				msg("u1", apimodels.ThreadRoot, false), msg("a1", "u1", false),
+			},
+			msgId:      "a1",
+			want:       map[string]bool{},
+			wantBranch: []string{"u1", "a1"},
+		},
+		{
+			name:       "not in window",
+			messages:   []apimodels.Message{msg("u5", "a4", false), msg("a5", "u5", false)},
+			msgId:      "a4",
+			want:       map[string]bool{},
+			wantBranch: []string{"u5", "a5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := SelectVariant(tt.messages, tt.msgId)
+			if !maps.Equal(changes, tt.want) {
+				t.Errorf("SelectVariant(%q) = %v, want %v", tt.msgId, changes, tt.want)
+			}
+			ApplyFlagChanges(tt.messages, changes)
+			if got := msgIds(ActiveBranch(tt.messages)); !slices.Equal(got, tt.wantBranch) {
+				t.Errorf("ActiveBranch() after select = %v, want %v", got, tt.wantBranch)
+			}
+		})
+	}
+}
+
+func TestBranchFrom(t *testing.T) {
+	tests := []struct {
+		name       string
+		messages   []apimodels.Message
+		parentId   string
+		want       map[string]bool
+		wantBranch []string
+	}{
+		{
+			name:       "regenerate a legacy reply",
+			messages:   []apimodels.Message{msg("u1", "", false), msg("a1", "", false)},
+			parentId:   "u1",
+			want:       map[string]bool{"a1": true},
+			wantBranch: []string{"u1"},
+		},
+		{
+			name: "regenerate with an inactive variant",
+			messages: []apimodels.Message{
+				msg("u1", apimodels.ThreadRoot, false), msg("a1", "u1", true), msg("a1b", "u1", false),
+			},
+			parentId:   "u1",
+			want:       map[string]bool{"a1b": true},
+			wantBranch: []string{"u1"},
+		},
+		{
+			name: "edit the first message",
+			messages: []apimodels.Message{
+				msg("u1", apimodels.ThreadRoot, false), msg("a1", "u1", false),
+			},
+			parentId:   apimodels.ThreadRoot,
+			want:       map[string]bool{"u1": true},
+			wantBranch: nil,
+		},
+		{
+			name: "edit a message on an inactive branch",
+			messages: []apimodels.Message{
+				msg("u1", apimodels.ThreadRoot, false), msg("a1", "u1", true), msg("u2", "a1", false),
+				msg("a1b", "u1", false), msg("u2b", "a1b", false),
+			},
+			parentId:   "a1",
+			want:       map[string]bool{"a1": false, "a1b": true, "u2": true},
+			wantBranch: []string{"u1", "a1"},
+		},
+		{
+			name:       "window starts mid-chat",
+			messages:   []apimodels.Message{msg("u5", "a4", false), msg("a5", "u5", false)},
+			parentId:   "a4",
+			want:       map[string]bool{"u5": true},
+			wantBranch: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := BranchFrom(tt.messages, tt.parentId)
+			if !maps.Equal(changes, tt.want) {
+				t.Errorf("BranchFrom(%q) = %v, want %v", tt.parentId, changes, tt.want)
+			}
+			ApplyFlagChanges(tt.messages, changes)
+			if got := msgIds(ActiveBranch(tt.messages)); !slices.Equal(got, tt.wantBranch) {
+				t.Errorf("ActiveBranch() after branching = %v, want %v", got, tt.wantBranch)
+			}
+		})
+	}
+}
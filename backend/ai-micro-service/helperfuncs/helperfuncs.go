@@ -3,8 +3,11 @@ package helperfuncs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"slices"
 	"strings"
 
 	"github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/config"
@@ -12,6 +15,8 @@ import (
 	grpcservices "github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/services/grpc-services"
 	vectordbservices "github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/services/vectorDB-services"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"google.golang.org/genai"
 )
 
@@ -67,9 +72,10 @@ func formatMemoryResults(results []apimodels.Memory) string {
 }
 
 // ///////////////////// VECTOR DATABASE HELPER FUNCS ////////////////////////
-func TriggerVectorSearch(userId, chatId, query string, fileIds []string) (string, error) {
+func TriggerVectorSearch(userId, chatId, query string, fileIds []string) (string, []grpcservices.QueryVectorResult, error) {
 	// TriggerVectorSearch performs a vector search using the provided userId, chatId, query, and fileIds.
-	// It constructs a VectorQueryRequest, calls the vector DB service, and returns a formatted result string or error.
+	// It constructs a VectorQueryRequest, calls the vector DB service, and returns a formatted result string
+	// alongside the raw results, or an error.
 	req := vectordbservices.VectorQueryRequest{
 		UserId:    userId,
 		ChatId:    chatId,
@@ -80,10 +86,10 @@ func TriggerVectorSearch(userId, chatId, query string, fileIds []string) (string
 
 	results, err := vectordbservices.VectorQueryService(req)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return formatVectorQueryResult(results), nil
+	return formatVectorQueryResult(results), results, nil
 }
 
 func formatVectorQueryResult(results []grpcservices.QueryVectorResult) string {
@@ -147,50 +153,83 @@ func UpdateSummaryInRedis(ctx context.Context, key string, newSummary string) {
 }
 
 // //////////////////////// DATABASE HELPER FUNCS ///////////////////////////////
-func FetchChatSummaryAndLastMessages(ctx context.Context, userId, chatId string) (summary string, messages []apimodels.Message, err error) {
-	filter := bson.M{"userId": userId, "chatId": chatId}
-
-	pipeline := bson.A{
-		bson.M{"$match": filter},
-		bson.M{"$project": bson.M{
-			"summary":  1,
-			"messages": bson.M{"$slice": bson.A{"$messages", -4}},
-		}},
+// FetchChatSummary returns the rolling summary stored on a chat; a missing chat has none.
+func FetchChatSummary(ctx context.Context, userId, chatId string) (string, error) {
+	var chat struct {
+		Summary string `bson:"summary"`
 	}
+	err := config.GetCollection("chats").FindOne(ctx,
+		bson.M{"userId": userId, "chatId": chatId},
+		options.FindOne().SetProjection(bson.M{"summary": 1}),
+	).Decode(&chat)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("[DB] No chat found for userId=%s, chatId=%s", userId, chatId)
+			return "", nil
+		}
+		log.Printf("[DB] FindOne error: %v", err)
+		return "", err
+	}
+	return chat.Summary, nil
+}
 
-	collection := config.GetCollection("chats")
-	cursor, err := collection.Aggregate(ctx, pipeline)
-
+// FetchLastMessages returns up to n of a chat's newest stored messages, oldest first.
+func FetchLastMessages(ctx context.Context, userId, chatId string, n int64) ([]apimodels.Message, error) {
+	collection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+	cursor, err := collection.Find(ctx,
+		bson.M{"userId": userId, "chatId": chatId},
+		options.Find().SetSort(bson.D{{Key: "seq", Value: -1}}).SetLimit(n),
+	)
 	if err != nil {
-		log.Printf("[DB] Aggregate error: %v", err)
-		return "", nil, err
+		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var result []struct {
-		Summary  string              `bson:"summary"`
-		Messages []apimodels.Message `bson:"messages"`
+	var messages []apimodels.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
 	}
+	slices.Reverse(messages)
+	return messages, nil
+}
 
-	if err := cursor.All(ctx, &result); err != nil {
-		log.Printf("[DB] Cursor decode error: %v", err)
-		return "", nil, err
+// StoreChatSummary saves a chat's summary in Mongo, and in its Redis hash if the chat is live.
+func StoreChatSummary(ctx context.Context, userId, chatId, summary string) error {
+	_, err := config.GetCollection("chats").UpdateOne(ctx,
+		bson.M{"userId": userId, "chatId": chatId},
+		bson.M{"$set": bson.M{"summary": summary}},
+	)
+	if err != nil {
+		return err
 	}
 
-	if len(result) == 0 {
-		log.Printf("[DB] No chat found for userId=%s, chatId=%s", userId, chatId)
-		return "", nil, nil
+	key := fmt.Sprintf("chats:%s:%s", userId, chatId)
+	if n, err := config.RedisClient.Exists(ctx, key).Result(); err == nil && n > 0 {
+		UpdateSummaryInRedis(ctx, key, summary)
 	}
+	return nil
+}
 
-	return result[0].Summary, result[0].Messages, nil
+// SetChatTitleIfDefault renames a chat that still has the default name. Reports whether
+// it did; a chat the user has named in the meantime is left alone.
+func SetChatTitleIfDefault(ctx context.Context, userId, chatId, title string) (bool, error) {
+	result, err := config.GetCollection("chats").UpdateOne(ctx,
+		bson.M{"userId": userId, "chatId": chatId, "name": apimodels.DefaultChatName},
+		bson.M{"$set": bson.M{"name": title}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
 }
 
 ////////////////////////// AI HELPER FUNCS ///////////////////////////////
 
 func GetFormattedLastNMessages(messages []apimodels.Message, n int) string {
-	// GetFormattedLastNMessages returns a formatted string of the last n messages.
+	// GetFormattedLastNMessages returns a formatted string of the last n messages on the active branch.
 	// Each message is shown as "ROLE : content". If more than n messages, only the last n are included.
 	var sb strings.Builder
+	messages = ActiveBranch(messages)
 	if len(messages) > n {
 		messages = messages[len(messages)-n:]
 	}
@@ -232,3 +271,51 @@ Update the summary so it captures only essential context that helps continue the
 
 	return strings.TrimSpace(resp.Text())
 }
+
+// MaxChatTitleLength caps generated titles, in characters.
+const MaxChatTitleLength = 60
+
+// GenerateChatTitle asks Gemini for a short title for a chat from its first exchange.
+// Returns an empty string if no usable title came back.
+func GenerateChatTitle(ctx context.Context, client *genai.Client, query, reply string) string {
+	content := fmt.Sprintf(`Write a title for the conversation below.
+- 3 to 6 words, in the language of the user's message.
+- No quotes, no trailing punctuation, no emojis, no markdown.
+- Reply with the title only.
+
+USER : %s
+AI : %s`,
+		query,
+		reply,
+	)
+
+	resp, err := client.Models.GenerateContent(
+		ctx,
+		"gemini-2.5-flash",
+		genai.Text(content),
+		nil,
+	)
+	if err != nil {
+		log.Printf("🔴 Error during title generation: %v", err)
+		return ""
+	}
+
+	title, _, _ := strings.Cut(strings.TrimSpace(resp.Text()), "\n")
+	title = strings.Trim(strings.TrimSpace(title), "\"'`*#.")
+	if runes := []rune(title); len(runes) > MaxChatTitleLength {
+		title = strings.TrimSpace(string(runes[:MaxChatTitleLength]))
+	}
+	return title
+}
+
+// RebuildSummary summarizes a history from scratch by folding it into the rolling
+// summary 6 messages at a time, the same window the live chat path uses.
+func RebuildSummary(ctx context.Context, client *genai.Client, messages []apimodels.Message) string {
+	summary := ""
+	messages = ActiveBranch(messages)
+	for start := 0; start < len(messages); start += 6 {
+		end := min(start+6, len(messages))
+		summary = GetLatestSummarization(ctx, client, summary, messages[start:end])
+	}
+	return summary
+}
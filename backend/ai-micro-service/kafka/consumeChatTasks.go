@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/helperfuncs"
+)
+
+// summaryRebuildWindow bounds how much history a rebuilt summary covers.
+const summaryRebuildWindow = 30
+
+type ChatTask struct {
+	Operation string `json:"operation"` // "summarize"
+	UserID    string `json:"userId"`
+	ChatID    string `json:"chatId"`
+}
+
+// StartChatTaskConsumer starts consuming the chat_tasks topic
+func StartChatTaskConsumer(brokers []string, topic, groupId string, publisher *PublisherHandler) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_8_0_0
+	config.Consumer.Group.Rebalance.Strategy = sarama.NewBalanceStrategyRange()
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	group, err := sarama.NewConsumerGroup(brokers, groupId, config)
+	if err != nil {
+		log.Fatalf("[ChatTaskConsumerGroup] group error: %v", err)
+	}
+
+	handler := &chatTaskConsumerHandler{
+		publisher: publisher,
+	}
+	ctx := context.Background()
+
+	for {
+		if err := group.Consume(ctx, []string{topic}, handler); err != nil {
+			log.Printf("[ChatTaskConsumerGroup] consume error: %v", err)
+		}
+	}
+}
+
+type chatTaskConsumerHandler struct {
+	publisher *PublisherHandler
+}
+
+func (chatTaskConsumerHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
+func (chatTaskConsumerHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+
+func (h *chatTaskConsumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		var task ChatTask
+		if err := json.Unmarshal(msg.Value, &task); err != nil {
+			log.Printf("[ChatTaskConsumerGroup] JSON unmarshal failed: %v", err)
+			sess.MarkMessage(msg, "")
+			continue
+		}
+
+		switch task.Operation {
+		case "summarize":
+			if err := handleSummaryRebuild(task); err != nil {
+				log.Printf("[ChatTaskConsumerGroup] summary rebuild failed for chat=%s: %v", task.ChatID, err)
+			}
+		default:
+			log.Printf("[ChatTaskConsumerGroup] Unknown operation: %s", task.Operation)
+		}
+		sess.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+// handleSummaryRebuild regenerates a chat's rolling summary from its stored
+// history, e.g. after the chat was forked from another one.
+func handleSummaryRebuild(task ChatTask) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	messages, err := helperfuncs.FetchLastMessages(ctx, task.UserID, task.ChatID, summaryRebuildWindow)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	summary := helperfuncs.RebuildSummary(ctx, config.GeminiClient, messages)
+	if err := helperfuncs.StoreChatSummary(ctx, task.UserID, task.ChatID, summary); err != nil {
+		return err
+	}
+
+	log.Printf("[ChatTaskConsumerGroup] Rebuilt summary for user=%s chat=%s from %d message(s)", task.UserID, task.ChatID, len(messages))
+	return nil
+}
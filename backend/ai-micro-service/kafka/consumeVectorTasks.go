@@ -3,6 +3,7 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -150,6 +151,7 @@ func handleVectorization(task VectorizationTask, h *vectorTaskConsumerHandler) e
 			},
 		}
 		_ = databaseservices.UpdateOneByID(os.Getenv("FILE_COLLECTION"), pdfEntry.ID, update)
+		updateRelinkedCopies(pdfEntry.ID, update)
 		log.Printf("[handleVectorization] Vectorization FAILED: %v", err)
 	} else {
 		// Update DB: success
@@ -164,6 +166,7 @@ func handleVectorization(task VectorizationTask, h *vectorTaskConsumerHandler) e
 		} else {
 			log.Printf("[handleVectorization] File %s marked as vectorized", task.FileID)
 		}
+		updateRelinkedCopies(pdfEntry.ID, update)
 	}
 
 	// Step 4: Publish final status to Kafka
@@ -197,33 +200,76 @@ func handleVectorDocDeletion(task VectorizationTask, h *vectorTaskConsumerHandle
 		return err
 	}
 
-	// Step 3: Delete from vectorizer if needed
-	if fileEntry.IsVectorDBCreated {
-		if _, err := grpcservices.RequestDeleteDocsToPythonVectorizer([]apimodels.Upload{fileEntry}); err != nil {
+	collection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
+
+	// Step 3: Files re-linked into forked chats share the source upload's stored file
+	// and vectors; only the last entry referencing them may delete them.
+	source := fileEntry.VectorSource()
+	shared := fileEntry.SourceFileId != ""
+	if !shared {
+		n, err := collection.CountDocuments(context.TODO(), bson.M{"sourceFileId": fileEntry.ID.Hex()})
+		if err != nil {
+			publishStatus(h, "deletion_status", task, fileEntry, "error", fmt.Sprintf("reference check failed: %v", err))
+			return err
+		}
+		shared = n > 0
+	}
+
+	// Step 4: Remove file entry from DB
+	if _, err := collection.DeleteOne(context.TODO(), filter); err != nil {
+		log.Printf("[handleVectorDocDeletion] Failed to delete file entry: %v", err)
+		publishStatus(h, "deletion_status", task, fileEntry, "error", fmt.Sprintf("db delete failed: %v", err))
+		return err
+	}
+	log.Printf("[handleVectorDocDeletion] File entry deleted for fileId=%s", task.FileID)
+
+	lastReference := true
+	if shared {
+		remaining, err := collection.CountDocuments(context.TODO(), bson.M{"$or": bson.A{
+			bson.M{"_id": source.ID},
+			bson.M{"sourceFileId": source.ID.Hex()},
+		}})
+		if err != nil {
+			log.Printf("[handleVectorDocDeletion] Reference count failed for source=%s: %v", source.ID.Hex(), err)
+			status = "error"
+			errorMsg = fmt.Sprintf("reference count failed: %v", err)
+		}
+		lastReference = err == nil && remaining == 0
+	}
+
+	// Step 5: Delete from vectorizer if needed
+	if lastReference && fileEntry.IsVectorDBCreated {
+		if _, err := grpcservices.RequestDeleteDocsToPythonVectorizer([]apimodels.Upload{source}); err != nil {
 			log.Printf("[handleVectorDocDeletion] Vector docs deletion failed for file=%s: %v", task.FileID, err)
 			status = "error"
 			errorMsg = fmt.Sprintf("vector deletion failed: %v", err)
 		} else {
-			log.Printf("[handleVectorDocDeletion] Vector docs deleted for file=%s", task.FileID)
+			log.Printf("[handleVectorDocDeletion] Vector docs deleted for file=%s", source.ID.Hex())
 		}
 	}
 
-	// Step 4: Remove file entry from DB
-	collection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
-	if _, err := collection.DeleteOne(context.TODO(), filter); err != nil {
-		log.Printf("[handleVectorDocDeletion] Failed to delete file entry: %v", err)
-		status = "error"
-		errorMsg = fmt.Sprintf("db delete failed: %v", err)
-	} else {
-		log.Printf("[handleVectorDocDeletion] File entry deleted for fileId=%s", task.FileID)
+	// The micro-service leaves shared files on disk; whoever drops the last reference removes it
+	if shared && lastReference && fileEntry.Path != "" {
+		if err := os.Remove(fileEntry.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[handleVectorDocDeletion] Failed to remove shared file %s: %v", fileEntry.Path, err)
+		}
 	}
 
-	// Step 5: Publish status to Kafka
+	// Step 6: Publish status to Kafka
 	publishStatus(h, "deletion_status", task, fileEntry, status, errorMsg)
 
 	return nil
 }
 
+// updateRelinkedCopies applies a vectorization status update to uploads re-linked
+// from this one into forked chats, which share its vectors.
+func updateRelinkedCopies(fileId primitive.ObjectID, update bson.M) {
+	collection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
+	if _, err := collection.UpdateMany(context.TODO(), bson.M{"sourceFileId": fileId.Hex()}, update); err != nil {
+		log.Printf("[updateRelinkedCopies] Failed to update copies of file=%s: %v", fileId.Hex(), err)
+	}
+}
+
 // helper
 func publishStatus(
 	h *vectorTaskConsumerHandler,
@@ -4,6 +4,7 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"time"
 
@@ -57,27 +58,53 @@ func (h *inputHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sara
 			continue
 		}
 
-		// Send "start" control before streaming
-		start := types.OutgoingMessage{
-			Type:   "control",
-			MsgId:  incoming.MsgId,
-			ChatId: incoming.ChatId,
-			UserId: incoming.UserId,
-			Role:   "ai",
-			Signal: "start",
-		}
-		if data, err := json.Marshal(start); err == nil {
-			if err := h.publisher.SendMessage("server_reply", key, data); err != nil {
-				log.Printf("❌ Kafka publish failed (start control): %v", err)
+		// Selecting a variant only moves the active branch; nothing is generated
+		if incoming.Action == types.QuerySelect {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			err := aiservices.SelectMessage(ctx, incoming.UserId, incoming.ChatId, incoming.TargetId)
+			cancel()
+			if err != nil {
+				log.Printf("[QueryProcessingConsumerGroup] Failed to select %s: %v", incoming.TargetId, err)
+				h.sendErrorControl(key, incoming, err)
+			} else {
+				h.sendControl(key, types.OutgoingMessage{
+					Type:     "control",
+					MsgId:    incoming.MsgId,
+					ChatId:   incoming.ChatId,
+					UserId:   incoming.UserId,
+					Role:     "ai",
+					Signal:   "selected",
+					TargetId: incoming.TargetId,
+				})
 			}
-		} else {
-			log.Printf("❌ Failed to marshal start control: %v", err)
+			sess.MarkMessage(msg, "")
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		turn, err := aiservices.PrepareTurn(ctx, incoming)
+		cancel()
+		if err != nil {
+			log.Printf("[QueryProcessingConsumerGroup] Failed to prepare %s turn: %v", incoming.Action, err)
+			h.sendErrorControl(key, incoming, err)
+			sess.MarkMessage(msg, "")
+			continue
 		}
 
+		// Send "start" control before streaming
+		h.sendControl(key, types.OutgoingMessage{
+			Type:     "control",
+			MsgId:    incoming.MsgId,
+			ChatId:   incoming.ChatId,
+			UserId:   incoming.UserId,
+			Role:     "ai",
+			Signal:   "start",
+			ReplyId:  turn.ReplyId,
+			ParentId: turn.Parent,
+		})
+
 		// Process user query
-		err := aiservices.StreamUserQueryResponse(
-			incoming.UserId, incoming.ChatId, incoming.Content,
-			incoming.FileIds, incoming.MemIds,
+		err = aiservices.StreamUserQueryResponse(turn,
 			func(chunk string, chunkIdx int) {
 				out := types.OutgoingMessage{
 					Type:     "chunk",
@@ -106,6 +133,16 @@ func (h *inputHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sara
 					log.Printf("[QueryProcessingConsumerGroup] Kafka publish failed for signal: %v", err)
 				}
 				log.Printf("✅ Raised DB flush ticket for UserId: %s and ChatId: %s", incoming.UserId, incoming.ChatId)
+			}, func(title string) {
+				h.sendControl(key, types.OutgoingMessage{
+					Type:    "control",
+					MsgId:   incoming.MsgId,
+					ChatId:  incoming.ChatId,
+					UserId:  incoming.UserId,
+					Role:    "ai",
+					Content: title,
+					Signal:  "chat_renamed",
+				})
 			})
 
 		if err != nil {
@@ -113,21 +150,16 @@ func (h *inputHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sara
 		}
 
 		// After streaming is done
-		end := types.OutgoingMessage{
-			Type:   "control",
-			MsgId:  incoming.MsgId,
-			ChatId: incoming.ChatId,
-			UserId: incoming.UserId,
-			Role:   "ai",
-			Signal: "end",
-		}
-		if data, err := json.Marshal(end); err == nil {
-			if err := h.publisher.SendMessage("server_reply", key, data); err != nil {
-				log.Printf("❌ Kafka publish failed (end control): %v", err)
-			}
-		} else {
-			log.Printf("❌ Failed to marshal end control: %v", err)
-		}
+		h.sendControl(key, types.OutgoingMessage{
+			Type:     "control",
+			MsgId:    incoming.MsgId,
+			ChatId:   incoming.ChatId,
+			UserId:   incoming.UserId,
+			Role:     "ai",
+			Signal:   "end",
+			ReplyId:  turn.ReplyId,
+			ParentId: turn.Parent,
+		})
 
 		// Commit offset
 		sess.MarkMessage(msg, "")
@@ -135,3 +167,33 @@ func (h *inputHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sara
 
 	return nil
 }
+
+// sendControl publishes a control message back to the chat's sockets.
+func (h *inputHandler) sendControl(key string, out types.OutgoingMessage) {
+	data, err := json.Marshal(out)
+	if err != nil {
+		log.Printf("❌ Failed to marshal %s control: %v", out.Signal, err)
+		return
+	}
+	if err := h.publisher.SendMessage("server_reply", key, data); err != nil {
+		log.Printf("❌ Kafka publish failed (%s control): %v", out.Signal, err)
+	}
+}
+
+// sendErrorControl tells the chat's sockets that a query could not be carried out.
+func (h *inputHandler) sendErrorControl(key string, incoming types.IncomingQuery, err error) {
+	reason := "query could not be processed, please retry"
+	if errors.Is(err, aiservices.ErrTargetNotFound) || errors.Is(err, aiservices.ErrNotUserMessage) {
+		reason = err.Error()
+	}
+	h.sendControl(key, types.OutgoingMessage{
+		Type:     "control",
+		MsgId:    incoming.MsgId,
+		ChatId:   incoming.ChatId,
+		UserId:   incoming.UserId,
+		Role:     "ai",
+		Content:  reason,
+		Signal:   "error",
+		TargetId: incoming.TargetId,
+	})
+}
@@ -30,6 +30,9 @@ func main() {
 	// Handle Vector Docs Creation and Deletion
 	go kafka.StartVectorFileConsumer(brokers, "vectorize_file", "vector_group", publisherHandler)
 
+	// Handle background chat work requested by the micro-service (summary rebuilds)
+	go kafka.StartChatTaskConsumer(brokers, "chat_tasks", "chat_task_group", publisherHandler)
+
 	// Handle User Query Processing and server-reply publishing
 	go kafka.StartUserQueryProcessing(brokers, "user_query", "ws_server_group", publisherHandler)
 
@@ -18,13 +18,61 @@ type Upload struct {
 	Status            string             `bson:"status" json:"status"`
 	Error             string             `bson:"error" json:"error"`
 	Persist           bool               `bson:"persist" json:"persist"`
+	// Set on uploads re-linked into a forked chat; the file and vectors belong to the source upload
+	SourceFileId string `bson:"sourceFileId,omitempty" json:"sourceFileId,omitempty"`
+	SourceUserId string `bson:"sourceUserId,omitempty" json:"sourceUserId,omitempty"`
+	SourceChatId string `bson:"sourceChatId,omitempty" json:"sourceChatId,omitempty"`
 }
 
+// VectorSource returns the upload identity the vectorizer stores this file's vectors under.
+func (u Upload) VectorSource() Upload {
+	if u.SourceFileId == "" {
+		return u
+	}
+	src := u
+	if id, err := primitive.ObjectIDFromHex(u.SourceFileId); err == nil {
+		src.ID = id
+	}
+	src.UserId = u.SourceUserId
+	src.ChatId = u.SourceChatId
+	return src
+}
+
+// ThreadRoot is the ParentId of a message that starts a chat.
+const ThreadRoot = "root"
+
+// DefaultChatName is the name chats are created with; it is replaced by a generated
+// title after the first exchange.
+const DefaultChatName = "New Chat"
+
 type Message struct {
 	MsgID     string `json:"msgId" bson:"msgId"`
 	Role      string `json:"role" bson:"role"`
 	Content   string `json:"content" bson:"content"`
 	Timestamp string `json:"timestamp" bson:"timestamp"`
+	// ParentId is the message this one follows. Siblings sharing a parent are
+	// variants (edits or regenerated replies); all but one are Inactive. Messages
+	// stored before branching existed have no ParentId and follow the one before them.
+	ParentId string `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	Inactive bool   `json:"inactive,omitempty" bson:"inactive,omitempty"`
+	// Generation records what produced an AI message; nil on user messages.
+	Generation *Generation `json:"generation,omitempty" bson:"generation,omitempty"`
+}
+
+// Generation is the model, prompt and retrieved context an AI reply was produced with.
+type Generation struct {
+	Model         string            `json:"model" bson:"model"`
+	PromptVersion string            `json:"promptVersion" bson:"promptVersion"`
+	Sources       []RetrievedSource `json:"sources,omitempty" bson:"sources,omitempty"`
+	FileIds       []string          `json:"fileIds,omitempty" bson:"fileIds,omitempty"`
+	MemIds        []string          `json:"memIds,omitempty" bson:"memIds,omitempty"`
+}
+
+// RetrievedSource is one document chunk returned by the vector search for a reply.
+type RetrievedSource struct {
+	Source   string  `json:"source" bson:"source"`
+	Page     int32   `json:"page" bson:"page"`
+	Distance float32 `json:"distance" bson:"distance"`
 }
 
 type Memory struct {
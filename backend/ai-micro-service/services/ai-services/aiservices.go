@@ -10,11 +10,21 @@ import (
 	"github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/config"
 	"github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/helperfuncs"
 	apimodels "github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/models/api-models"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"google.golang.org/genai"
 )
 
-func StreamUserQueryResponse(userId, chatId, query string, filesIds, memIds []string, sendChunk func(chunk string, chunkIdx int), sendSignal func(signal string)) error {
+const (
+	// ChatModel answers chat queries.
+	ChatModel = "gemini-2.5-flash"
+	// PromptVersion names the chat prompt below; bump it whenever the prompt changes
+	// so feedback can be told apart by the prompt that produced the reply.
+	PromptVersion = "chat-v1"
+)
+
+// StreamUserQueryResponse answers a prepared turn, streaming the reply through sendChunk
+// and storing it as the active variant under turn.Parent. After a chat's first exchange
+// it titles the chat in the background and reports the new title through renameChat.
+func StreamUserQueryResponse(turn *Turn, sendChunk func(chunk string, chunkIdx int), sendSignal func(signal string), renameChat func(title string)) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -24,45 +34,33 @@ func StreamUserQueryResponse(userId, chatId, query string, filesIds, memIds []st
 		return fmt.Errorf("internal error: AI module unavailable")
 	}
 
-	KEY := fmt.Sprintf("chats:%s:%s", userId, chatId)
+	userId, chatId, query := turn.UserId, turn.ChatId, turn.Query
+	filesIds, memIds := turn.FileIds, turn.MemIds
+	summary, messages := turn.Summary, turn.History
 
-	// 1. Fetch chats from Redis or fallback to DB
-	summary, messages, err := helperfuncs.FetchChatsFromRedis(ctx, KEY)
-	if err != nil || (summary == "" && len(messages) == 0) {
-		log.Printf("[AIService] ⚠️ No Redis context for falling back to DB...")
-		dbSummary, dbMessages, dbErr := helperfuncs.FetchChatSummaryAndLastMessages(ctx, userId, chatId)
-		helperfuncs.UpdateSummaryInRedis(ctx, KEY, dbSummary)
-		if dbErr != nil {
-			log.Printf("[AIService] ❌ DB fetch failed proceeding without context")
-			summary, messages = "", nil
-		} else {
-			log.Printf("[AIService] ✅ Loaded context from DB.")
-			summary, messages = dbSummary, dbMessages
-			helperfuncs.UpdateSummaryInRedis(ctx, KEY, dbSummary)
-		}
-	} else {
-		log.Printf("[AIService] ✅ Loaded context from Redis")
-	}
+	KEY := fmt.Sprintf("chats:%s:%s", userId, chatId)
 
-	// 2. Add user message
-	userMsg := apimodels.Message{
-		MsgID:     primitive.NewObjectID().Hex(),
-		Timestamp: time.Now().Format(time.RFC3339),
-		Role:      "user",
-		Content:   query,
+	// 1-2. Add user message; a regenerated reply reuses the one already stored
+	if turn.UserMsg != nil {
+		helperfuncs.AppendMessageToRedis(ctx, KEY, *turn.UserMsg)
 	}
-	go helperfuncs.AppendMessageToRedis(ctx, KEY, userMsg)
 
 	//3. Vector search
-	vectorQueryResult, err := helperfuncs.TriggerVectorSearch(userId, chatId, query, filesIds)
+	generation := &apimodels.Generation{Model: ChatModel, PromptVersion: PromptVersion, FileIds: filesIds}
+	vectorQueryResult, vectorResults, err := helperfuncs.TriggerVectorSearch(userId, chatId, query, filesIds)
 	if err != nil {
 		log.Printf("[AIService] ⚠️ Proceeding without vector results ...")
 	}
+	for _, r := range vectorResults {
+		generation.Sources = append(generation.Sources, apimodels.RetrievedSource{Source: r.Source, Page: r.Page, Distance: r.Distance})
+	}
 
 	//4. Memory search
 	memorySearchResult, err := helperfuncs.SearchMemoriesInDB(ctx, userId, chatId, memIds)
 	if err != nil {
 		log.Printf("[AIService] ⚠️ Proceeding without memories...")
+	} else {
+		generation.MemIds = memIds
 	}
 	// 5. Format Prompt
 	prompt := fmt.Sprintf(`You are a helpful, friendly, and conversational AI assistant.  
@@ -124,7 +122,7 @@ func StreamUserQueryResponse(userId, chatId, query string, filesIds, memIds []st
 	var aiResponseBuilder strings.Builder
 	iter := client.Models.GenerateContentStream(
 		ctx,
-		"gemini-2.5-flash",
+		ChatModel,
 		genai.Text(prompt),
 		nil,
 	)
@@ -148,10 +146,12 @@ func StreamUserQueryResponse(userId, chatId, query string, filesIds, memIds []st
 
 	aiReply := aiResponseBuilder.String()
 	aiMessage := apimodels.Message{
-		MsgID:     primitive.NewObjectID().Hex(),
-		Timestamp: time.Now().Format(time.RFC3339),
-		Role:      "ai",
-		Content:   aiReply,
+		MsgID:      turn.ReplyId,
+		Timestamp:  time.Now().Format(time.RFC3339),
+		Role:       "ai",
+		Content:    aiReply,
+		ParentId:   turn.Parent,
+		Generation: generation,
 	}
 
 	// 6. Add AI message
@@ -164,7 +164,12 @@ func StreamUserQueryResponse(userId, chatId, query string, filesIds, memIds []st
 		helperfuncs.UpdateSummaryInRedis(ctx, KEY, newSummary)
 	}
 
-	// 8. Trigger a flush to flush redis(keep summary though) and update main DB if messages cross 6+ length.
+	// 8. Title a chat still called the default name once its first exchange is done
+	if len(turn.History) == 0 && aiReply != "" {
+		go titleChat(client, userId, chatId, query, aiReply, renameChat)
+	}
+
+	// 9. Trigger a flush to flush redis(keep summary though) and update main DB if messages cross 6+ length.
 	if err == nil && len(updatedMessages) >= 6 {
 		sendSignal("flush")
 	}
@@ -172,3 +177,23 @@ func StreamUserQueryResponse(userId, chatId, query string, filesIds, memIds []st
 	return nil
 
 }
+
+// titleChat generates a title from the first exchange and stores it if the chat still
+// has the default name. It gets its own context as the turn's is gone by the time it runs.
+func titleChat(client *genai.Client, userId, chatId, query, reply string, renameChat func(title string)) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	title := helperfuncs.GenerateChatTitle(ctx, client, query, reply)
+	if title == "" {
+		return
+	}
+	renamed, err := helperfuncs.SetChatTitleIfDefault(ctx, userId, chatId, title)
+	if err != nil {
+		log.Printf("[AIService] ⚠️ Failed to store title for chat %s: %v", chatId, err)
+		return
+	}
+	if renamed {
+		renameChat(title)
+	}
+}
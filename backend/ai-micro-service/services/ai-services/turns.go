@@ -0,0 +1,131 @@
+package aiservices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/helperfuncs"
+	apimodels "github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/models/api-models"
+	"github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrTargetNotFound = errors.New("target message not found in this chat")
+	ErrNotUserMessage = errors.New("target is not a user message")
+)
+
+// Turn is everything StreamUserQueryResponse needs to answer one query.
+type Turn struct {
+	UserId  string
+	ChatId  string
+	Query   string
+	UserMsg *apimodels.Message  // new user message to store before replying; nil when regenerating
+	ReplyId string              // msgId the AI reply is stored under
+	Parent  string              // msgId the AI reply attaches to
+	History []apimodels.Message // active branch leading up to the query
+	Summary string
+	FileIds []string
+	MemIds  []string
+}
+
+// PrepareTurn resolves where a send, edit or regenerate attaches in the chat's
+// message tree, makes that branch the active one and returns the turn to answer.
+func PrepareTurn(ctx context.Context, incoming types.IncomingQuery) (*Turn, error) {
+	summary, window, err := helperfuncs.LoadThreadWindow(ctx, incoming.UserId, incoming.ChatId)
+	if err != nil {
+		return nil, err
+	}
+
+	turn := &Turn{
+		UserId:  incoming.UserId,
+		ChatId:  incoming.ChatId,
+		ReplyId: primitive.NewObjectID().Hex(),
+		Summary: summary,
+		FileIds: incoming.FileIds,
+		MemIds:  incoming.MemIds,
+	}
+
+	// parent is where the query's user message hangs; the reply hangs under that message
+	var parent string
+	switch incoming.Action {
+	case types.QueryEdit:
+		target, targetParent, ok := helperfuncs.FindMessage(window, incoming.TargetId)
+		if !ok {
+			return nil, ErrTargetNotFound
+		}
+		if target.Role != "user" {
+			return nil, ErrNotUserMessage
+		}
+		parent = targetParent
+
+	case types.QueryRegenerate:
+		target, targetParent, ok := helperfuncs.FindMessage(window, incoming.TargetId)
+		if !ok {
+			return nil, ErrTargetNotFound
+		}
+		// Regenerating an AI reply means answering its user message again
+		if target.Role != "user" {
+			if target, _, ok = helperfuncs.FindMessage(window, targetParent); !ok {
+				return nil, ErrTargetNotFound
+			}
+			if target.Role != "user" {
+				return nil, ErrNotUserMessage
+			}
+		}
+		changes := helperfuncs.BranchFrom(window, target.MsgID)
+		if err := helperfuncs.PersistFlagChanges(ctx, turn.UserId, turn.ChatId, changes); err != nil {
+			return nil, err
+		}
+		helperfuncs.ApplyFlagChanges(window, changes)
+
+		path := helperfuncs.ThreadPath(window, target.MsgID)
+		turn.Query = target.Content
+		turn.Parent = target.MsgID
+		turn.History = path[:len(path)-1]
+		return turn, nil
+
+	default:
+		parent = apimodels.ThreadRoot
+		if branch := helperfuncs.ActiveBranch(window); len(branch) > 0 {
+			parent = branch[len(branch)-1].MsgID
+		}
+	}
+
+	changes := helperfuncs.BranchFrom(window, parent)
+	if err := helperfuncs.PersistFlagChanges(ctx, turn.UserId, turn.ChatId, changes); err != nil {
+		return nil, err
+	}
+	helperfuncs.ApplyFlagChanges(window, changes)
+
+	if parent != apimodels.ThreadRoot {
+		turn.History = helperfuncs.ThreadPath(window, parent)
+	}
+	// The client's msgId only correlates replies to the frame; the stored message gets
+	// its own id, reported back as the "start" control's parentId
+	turn.Query = incoming.Content
+	turn.UserMsg = &apimodels.Message{
+		MsgID:     primitive.NewObjectID().Hex(),
+		Timestamp: time.Now().Format(time.RFC3339),
+		Role:      "user",
+		Content:   incoming.Content,
+		ParentId:  parent,
+	}
+	turn.Parent = turn.UserMsg.MsgID
+	return turn, nil
+}
+
+// SelectMessage makes msgId the active variant among its siblings, along with every
+// message leading up to it, so later turns continue from that branch.
+func SelectMessage(ctx context.Context, userId, chatId, msgId string) error {
+	_, window, err := helperfuncs.LoadThreadWindow(ctx, userId, chatId)
+	if err != nil {
+		return err
+	}
+	if _, _, ok := helperfuncs.FindMessage(window, msgId); !ok {
+		return fmt.Errorf("%w: %s", ErrTargetNotFound, msgId)
+	}
+	return helperfuncs.PersistFlagChanges(ctx, userId, chatId, helperfuncs.SelectVariant(window, msgId))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/Recker-Dev/NextJs-GPT/backend/ai-micro-service/config"
@@ -76,11 +77,37 @@ func VectorQueryService(req VectorQueryRequest) ([]grpcservices.QueryVectorResul
 
 	// fmt.Printf("[VectorQueryService] userId=%s, chatId=%s, fileIds=%s\n", req.UserId, req.ChatId, strings.Join(req.FileIds, ","))
 
-	// gRPC call
-	response, err := grpcservices.SendQueryToPythonVectorizer(uploads, int32(req.TopK), queries)
-	if err != nil {
-		log.Printf("[VectorQueryService] Error processing grpc python service: %v", err)
-		return nil, fmt.Errorf("grpc error from Python service: %v", err)
+	// Vectors are stored per (userId, chatId) of the upload that created them, which
+	// for files re-linked into a fork is the source chat; query each group separately.
+	groups := map[string][]apimodels.Upload{}
+	var order []string
+	for _, u := range uploads {
+		src := u.VectorSource()
+		key := src.UserId + "_" + src.ChatId
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], src)
+	}
+
+	var response []grpcservices.QueryVectorResult
+	for _, key := range order {
+		// gRPC call
+		results, err := grpcservices.SendQueryToPythonVectorizer(groups[key], int32(req.TopK), queries)
+		if err != nil {
+			log.Printf("[VectorQueryService] Error processing grpc python service: %v", err)
+			return nil, fmt.Errorf("grpc error from Python service: %v", err)
+		}
+		response = append(response, results...)
+	}
+
+	// Each group returns up to TopK on its own; keep the closest TopK overall so the
+	// prompt context does not grow with the number of sources
+	if len(order) > 1 {
+		sort.SliceStable(response, func(i, j int) bool { return response[i].Distance < response[j].Distance })
+		if len(response) > req.TopK {
+			response = response[:req.TopK]
+		}
 	}
 
 	return response, nil
@@ -11,15 +11,26 @@ type IncomingControl struct {
 	SelectedMemories []string `json:"selectedMemories,omitempty"`
 	SelectedFileIds  []string `json:"selectedFileIds,omitempty"`
 }
+
+// Actions an IncomingQuery can carry; the WS service fills in QuerySend when absent.
+const (
+	QuerySend       = "send"       // new user message, answered by the AI
+	QueryEdit       = "edit"       // new variant of TargetId's user message, answered by the AI
+	QueryRegenerate = "regenerate" // another AI reply to TargetId (a user message or one of its replies)
+	QuerySelect     = "select"     // make TargetId the active variant among its siblings
+)
+
 type IncomingQuery struct {
 	MsgId     string    `json:"msgId" bson:"msgId"` // unique per message
 	ChatId    string    `json:"chatId" bson:"chatId"`
 	UserId    string    `json:"userId" bson:"userId"`
-	Role      string    `json:"role" bson:"role"`                           // "user"
-	Content   string    `json:"content" bson:"content"`                     // full text prompt
-	FileIds   []string  `json:"fileIds,omitempty" bson:"fileIds,omitempty"` // optional
-	MemIds    []string  `json:"memIds,omitempty" bson:"memIds,omitempty"`   // optional
-	Timestamp time.Time `json:"timestamp" bson:"timestamp"`                 // unix epoch ms
+	Action    string    `json:"action,omitempty" bson:"action,omitempty"`     // see Query* constants
+	TargetId  string    `json:"targetId,omitempty" bson:"targetId,omitempty"` // message acted on by edit/regenerate/select
+	Role      string    `json:"role" bson:"role"`                             // "user"
+	Content   string    `json:"content" bson:"content"`                       // full text prompt
+	FileIds   []string  `json:"fileIds,omitempty" bson:"fileIds,omitempty"`   // optional
+	MemIds    []string  `json:"memIds,omitempty" bson:"memIds,omitempty"`     // optional
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`                   // unix epoch ms
 }
 
 type OutgoingMessage struct {
@@ -27,10 +38,13 @@ type OutgoingMessage struct {
 	MsgId    string `json:"msgId"` // ties back to IncomingQuery
 	ChatId   string `json:"chatId"`
 	UserId   string `json:"userId"`
-	Role     string `json:"role"`     // "assistant"
-	Content  string `json:"content"`  // only for Type="chunk"
-	ChunkIdx int    `json:"chunkIdx"` // order of chunks
-	Signal   string `json:"signal"`   // only for Type="control" (e.g. "start", "end")
+	Role     string `json:"role"`               // "assistant"
+	Content  string `json:"content"`            // chunk text, the reason for an "error" control, or the new title for "chat_renamed"
+	ChunkIdx int    `json:"chunkIdx"`           // order of chunks
+	Signal   string `json:"signal"`             // only for Type="control" (e.g. "start", "end", "selected", "error", "chat_renamed")
+	ReplyId  string `json:"replyId,omitempty"`  // msgId the AI reply is stored under
+	ParentId string `json:"parentId,omitempty"` // stored id of the user message the reply answers
+	TargetId string `json:"targetId,omitempty"` // message made active, for "selected"
 }
 
 type OutgoingFileStatus struct {
@@ -0,0 +1,110 @@
+// Command migrate-messages moves chat history out of the legacy embedded
+// chats.messages array into the messages collection, then backfills the
+// messageCount/updatedAt/lastMessage head fields on chats missing them. It is
+// safe to re-run: messages already copied are skipped and migrated chats no
+// longer match.
+//
+// Run from the micro-service directory so .env is picked up:
+//
+//	go run ./cmd/migrate-messages
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	if err := godotenv.Load(".env"); err != nil {
+		log.Fatal("Error loading .env file")
+	}
+	config.ConnectDB("nextjs_gpt_chat")
+
+	if err := services.EnsureMessageIndexes(); err != nil {
+		log.Fatalf("Failed to create message indexes: %v", err)
+	}
+
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+	ctx := context.Background()
+
+	// Only chats still carrying an embedded array
+	filter := bson.M{"messages": bson.M{"$exists": true}}
+	cursor, err := chatCollection.Find(ctx, filter, options.Find().SetProjection(bson.M{
+		"userId":   1,
+		"chatId":   1,
+		"messages": 1,
+	}))
+	if err != nil {
+		log.Fatalf("Failed to list chats: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var chats, moved, failed int
+	for cursor.Next(ctx) {
+		var chat models.Chat
+		if err := cursor.Decode(&chat); err != nil {
+			log.Printf("❌ Skipping undecodable chat: %v", err)
+			failed++
+			continue
+		}
+
+		chatCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		n, err := services.MigrateEmbeddedMessages(chatCtx, chat)
+		cancel()
+		if err != nil {
+			log.Printf("❌ userId=%s chatId=%s: %v", chat.UserId, chat.ChatId, err)
+			failed++
+			continue
+		}
+
+		chats++
+		moved += n
+		log.Printf("✅ userId=%s chatId=%s: moved %d of %d message(s)", chat.UserId, chat.ChatId, n, len(chat.Messages))
+	}
+	if err := cursor.Err(); err != nil {
+		log.Fatalf("Cursor error: %v", err)
+	}
+
+	// Chats flushed before activity tracking existed need their head fields filled in
+	stale, err := chatCollection.Find(ctx, bson.M{"updatedAt": bson.M{"$exists": false}}, options.Find().SetProjection(bson.M{
+		"userId": 1,
+		"chatId": 1,
+	}))
+	if err != nil {
+		log.Fatalf("Failed to list chats for backfill: %v", err)
+	}
+	defer stale.Close(ctx)
+
+	var backfilled int
+	for stale.Next(ctx) {
+		var chat models.Chat
+		if err := stale.Decode(&chat); err != nil {
+			log.Printf("❌ Skipping undecodable chat: %v", err)
+			failed++
+			continue
+		}
+		if err := services.BackfillChatActivity(ctx, chat.UserId, chat.ChatId); err != nil {
+			log.Printf("❌ Backfill userId=%s chatId=%s: %v", chat.UserId, chat.ChatId, err)
+			failed++
+			continue
+		}
+		backfilled++
+	}
+	if err := stale.Err(); err != nil {
+		log.Fatalf("Cursor error: %v", err)
+	}
+
+	log.Printf("Migrated %d chat(s), %d message(s); backfilled activity on %d chat(s); %d failed", chats, moved, backfilled, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
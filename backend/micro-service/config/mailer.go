@@ -0,0 +1,18 @@
+package config
+
+import (
+	"log"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/mailer"
+)
+
+var Mailer mailer.Sender
+
+func InitMailer() {
+	sender, err := mailer.NewFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize mailer: %v", err)
+	}
+	Mailer = sender
+	log.Println("✅ Mailer initialized.")
+}
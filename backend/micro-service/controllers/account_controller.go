@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"net/http"
+
+	helperfuncs "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/helperfuncs"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Request does not need to wait; progress is tracked on the deletion job.
+func DeleteAccount(c *gin.Context) {
+	userId := c.Param("userId")
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId is needed"})
+		return
+	}
+
+	job, err := services.StartDeletionJob(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	go helperfuncs.RunAccountDeletion(job)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Account deletion started.",
+		"data":    job,
+	})
+}
+
+func GetDeletionJob(c *gin.Context) {
+	userId := c.Param("userId")
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId is needed"})
+		return
+	}
+
+	job, err := services.GetDeletionJob(userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": job})
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"github.com/gin-gonic/gin"
+)
+
+func CreateAPIKey(c *gin.Context) {
+	userId := c.Param("userId")
+	var input struct {
+		Name   string   `json:"name" binding:"required"`
+		Scopes []string `json:"scopes" binding:"required"`
+	}
+
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId is needed."})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	rawKey, key, err := services.CreateAPIKey(userId, input.Name, input.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Store this key now, it will not be shown again.",
+		"apiKey":  rawKey,
+		"data":    key,
+	})
+}
+
+func GetAPIKeys(c *gin.Context) {
+	userId := c.Param("userId")
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId is needed"})
+		return
+	}
+
+	keys, err := services.ListAPIKeys(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": keys})
+}
+
+func RevokeAPIKey(c *gin.Context) {
+	userId := c.Param("userId")
+	keyId := c.Param("keyId")
+
+	if userId == "" || keyId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId and keyId are required"})
+		return
+	}
+
+	if err := services.RevokeAPIKey(userId, keyId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "API key revoked"})
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
@@ -12,7 +13,26 @@ type AuthInput struct {
 	Password string `json:"password"`
 }
 
+// respondIfThrottled writes a 429 with Retry-After when err is a ThrottleError.
+func respondIfThrottled(c *gin.Context, err error) bool {
+	var throttled *services.ThrottleError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	c.Header("Retry-After", throttled.RetryAfterSeconds())
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": throttled.Error()})
+	return true
+}
+
 func RegisterUser(c *gin.Context) {
+	if err := services.CheckRegisterAllowed(c.ClientIP()); err != nil {
+		if !respondIfThrottled(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
 	var input AuthInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -41,15 +61,185 @@ func ValidateUser(c *gin.Context) {
 		return
 	}
 
+	if err := services.CheckLoginAllowed(input.Email, c.ClientIP()); err != nil {
+		if !respondIfThrottled(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
 	user, err := services.ValidateUser(input.Email, input.Password)
 	if err != nil {
+		if throttleErr := services.RecordLoginFailure(input.Email, c.ClientIP()); respondIfThrottled(c, throttleErr) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	services.RecordLoginSuccess(input.Email)
+
+	tokens, err := services.StartSession(user.ID.Hex(), user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"succss": true,
-		"userId": user.ID.Hex(),
-		"email":  user.Email,
+		"succss":           true,
+		"userId":           user.ID.Hex(),
+		"email":            user.Email,
+		"emailVerified":    user.EmailVerified,
+		"accessToken":      tokens.AccessToken,
+		"expiresAt":        tokens.AccessExpiresAt,
+		"refreshToken":     tokens.RefreshToken,
+		"refreshExpiresAt": tokens.RefreshExpiresAt,
 	})
 }
+
+type RefreshInput struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+func RefreshToken(c *gin.Context) {
+	var input RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := services.RefreshSession(input.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"accessToken":      tokens.AccessToken,
+		"expiresAt":        tokens.AccessExpiresAt,
+		"refreshToken":     tokens.RefreshToken,
+		"refreshExpiresAt": tokens.RefreshExpiresAt,
+	})
+}
+
+func Logout(c *gin.Context) {
+	var input RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.Logout(input.RefreshToken); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Logged out from this device"})
+}
+
+func LogoutEverywhere(c *gin.Context) {
+	userId := c.Param("userId")
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId is needed"})
+		return
+	}
+
+	revoked, err := services.LogoutEverywhere(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Logged out from all devices", "revokedSessions": revoked})
+}
+
+func RequestPasswordReset(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if err := services.CheckPasswordResetAllowed(input.Email, c.ClientIP()); err != nil {
+		if !respondIfThrottled(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	if err := services.RequestPasswordReset(input.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	// Same answer whether or not the email is registered.
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "If the email is registered, a reset link has been sent."})
+}
+
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if err := services.ConfirmPasswordReset(input.Token, input.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Password updated. Please log in again."})
+}
+
+func VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "token is required"})
+		return
+	}
+
+	if err := services.VerifyEmail(token); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Email verified!"})
+}
+
+func ResendVerificationEmail(c *gin.Context) {
+	userId := c.Param("userId")
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId is needed"})
+		return
+	}
+
+	if err := services.ResendVerificationEmail(userId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Verification email sent."})
+}
+
+func UnlockLogin(c *gin.Context) {
+	var input struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if err := services.UnlockLogin(input.Email, input.IP); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Login lockout cleared"})
+}
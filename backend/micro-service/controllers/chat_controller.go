@@ -1,13 +1,28 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	helperfuncs "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/helperfuncs"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
 	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
 	"github.com/gin-gonic/gin"
 )
 
+// chatOwnerId is the userId chat data is stored under. RequireChatRole sets it for
+// shared chats; otherwise it is the requester from the path.
+func chatOwnerId(c *gin.Context) string {
+	if ownerId := c.GetString("chatOwnerId"); ownerId != "" {
+		return ownerId
+	}
+	return c.Param("userId")
+}
+
 func Debug(c *gin.Context) {
 	userId := c.Param("userId")
 
@@ -29,7 +44,8 @@ func Debug(c *gin.Context) {
 func CreateChat(c *gin.Context) {
 	userId := c.Param("userId")
 	var input struct {
-		Name string `json:"name" binding:"required"`
+		Name        string `json:"name"` // empty gets models.DefaultChatName until a title is generated
+		WorkspaceId string `json:"workspaceId"`
 	}
 
 	if userId == "" {
@@ -42,7 +58,7 @@ func CreateChat(c *gin.Context) {
 		return
 	}
 
-	chatId, err := services.CreateChat(userId, input.Name)
+	chatId, err := services.CreateChat(userId, input.Name, input.WorkspaceId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -53,7 +69,7 @@ func CreateChat(c *gin.Context) {
 }
 
 func DeleteChat(c *gin.Context) {
-	userId := c.Param("userId")
+	userId := chatOwnerId(c)
 	chatId := c.Param("chatId")
 
 	if userId == "" || chatId == "" {
@@ -68,10 +84,90 @@ func DeleteChat(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Chat deleted!",
+		"message": "Chat moved to trash",
+		"purgeAt": time.Now().UTC().Add(services.TrashRetention()),
 	})
 }
 
+func GetTrashedChats(c *gin.Context) {
+	userId := c.Param("userId")
+
+	chats, err := services.GetTrashedChats(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "chats": chats})
+}
+
+func RestoreChat(c *gin.Context) {
+	userId := c.Param("userId")
+	chatId := c.Param("chatId")
+
+	if err := services.RestoreChat(userId, chatId); err != nil {
+		if errors.Is(err, services.ErrChatNotInTrash) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Chat restored"})
+}
+
+func UpdateChat(c *gin.Context) {
+	userId := chatOwnerId(c)
+	chatId := c.Param("chatId")
+
+	if userId == "" || chatId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId and chatId are required"})
+		return
+	}
+
+	var input models.ChatUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if err := services.UpdateChat(userId, chatId, input); err != nil {
+		if err.Error() == "user or chat not found" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Chat updated!"})
+}
+
+// CheckChatAccess is used by the WS service to confirm chat ownership before registering a socket.
+func CheckChatAccess(c *gin.Context) {
+	userId := chatOwnerId(c)
+	chatId := c.Param("chatId")
+
+	if userId == "" || chatId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId and chatId are required"})
+		return
+	}
+
+	exists, err := services.ChatExists(userId, chatId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "user or chat not found"})
+		return
+	}
+
+	// The WS service routes replies by ownerId and only lets editors send queries.
+	c.JSON(http.StatusOK, gin.H{"success": true, "ownerId": userId, "role": c.GetString("chatRole")})
+}
+
 func GetChatHeads(c *gin.Context) {
 	userId := c.Param("userId")
 	if userId == "" {
@@ -79,9 +175,33 @@ func GetChatHeads(c *gin.Context) {
 		return
 	}
 
-	heads, err := services.GetChatHeads(userId)
+	headsFilter := services.ChatHeadsFilter{
+		Archived: c.DefaultQuery("archived", services.ArchivedExclude),
+		FolderId: c.Query("folderId"),
+		Tag:      c.Query("tag"),
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit must be a positive integer"})
+			return
+		}
+		headsFilter.Limit = limit
+	}
+	if raw := c.Query("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "offset must be a non-negative integer"})
+			return
+		}
+		headsFilter.Offset = offset
+	}
+
+	heads, hasMore, err := services.GetChatHeads(userId, headsFilter)
 	if err != nil {
-		if err.Error() == "user not found" {
+		if errors.Is(err, services.ErrInvalidArchivedFilter) || errors.Is(err, services.ErrInvalidTag) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		} else if err.Error() == "user not found" {
 			// More semantic than 400
 			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "user not found"})
 		} else {
@@ -90,11 +210,11 @@ func GetChatHeads(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": heads})
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": heads, "hasMore": hasMore})
 }
 
 func GetChatMessages(c *gin.Context) {
-	userId := c.Param("userId")
+	userId := chatOwnerId(c)
 	chatId := c.Param("chatId")
 
 	if userId == "" || chatId == "" {
@@ -102,10 +222,29 @@ func GetChatMessages(c *gin.Context) {
 		return
 	}
 
-	messages, err := services.GetChatMessages(userId, chatId)
+	page := services.MessagePage{
+		Before: c.Query("before"),
+		After:  c.Query("after"),
+	}
+	if page.Before != "" && page.After != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "only one of before/after may be set"})
+		return
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit must be a positive integer"})
+			return
+		}
+		page.Limit = limit
+	}
+
+	messages, err := services.GetChatMessages(userId, chatId, page)
 	if err != nil {
 		// Distinguish between not found vs internal errors
-		if err.Error() == "user or chat not found" {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		} else if err.Error() == "user or chat not found" {
 			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
@@ -118,7 +257,7 @@ func GetChatMessages(c *gin.Context) {
 }
 
 func AddChatMemory(c *gin.Context) {
-	userId := c.Param("userId")
+	userId := chatOwnerId(c)
 	chatId := c.Param("chatId")
 
 	if userId == "" || chatId == "" {
@@ -147,7 +286,7 @@ func AddChatMemory(c *gin.Context) {
 }
 
 func GetChatMemories(c *gin.Context) {
-	userId := c.Param("userId")
+	userId := chatOwnerId(c)
 	chatId := c.Param("chatId")
 
 	if userId == "" || chatId == "" {
@@ -170,7 +309,7 @@ func GetChatMemories(c *gin.Context) {
 }
 
 func DeleteChatMemory(c *gin.Context) {
-	userId := c.Param("userId")
+	userId := chatOwnerId(c)
 	chatId := c.Param("chatId")
 	memId := c.Param("memId")
 
@@ -188,7 +327,7 @@ func DeleteChatMemory(c *gin.Context) {
 }
 
 func SetPersistanceChatMemory(c *gin.Context) {
-	userId := c.Param("userId")
+	userId := chatOwnerId(c)
 	chatId := c.Param("chatId")
 	memId := c.Param("memId")
 
@@ -224,3 +363,121 @@ func SetPersistanceChatMemory(c *gin.Context) {
 		"memId":   memId})
 
 }
+
+func SearchChats(c *gin.Context) {
+	userId := c.Param("userId")
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId is needed"})
+		return
+	}
+
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit must be a positive integer"})
+			return
+		}
+	}
+
+	hits, err := services.Search(userId, c.Query("q"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrEmptyQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": hits})
+}
+
+func ExportChat(c *gin.Context) {
+	userId := chatOwnerId(c)
+	chatId := c.Param("chatId")
+	format := c.DefaultQuery("format", services.ExportMarkdown)
+
+	if userId == "" || chatId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId and chatId are required"})
+		return
+	}
+
+	export, err := services.BuildChatExport(userId, chatId)
+	if err != nil {
+		if err.Error() == "user or chat not found" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	data, contentType, err := services.RenderChatExport(export, format)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownExportFormat) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, exportFileName(export.Name, chatId), format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// exportFileName turns a chat name into a safe download name, falling back to the chatId.
+func exportFileName(name, chatId string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r == ' ':
+			return '_'
+		default:
+			return -1
+		}
+	}, name)
+	if safe == "" {
+		return chatId
+	}
+	return safe
+}
+
+// ForkChat branches a chat at a message into a new chat owned by the requester.
+func ForkChat(c *gin.Context) {
+	requesterId := c.Param("userId")
+	ownerId := chatOwnerId(c)
+	chatId := c.Param("chatId")
+
+	var input struct {
+		MsgId string `json:"msgId" binding:"required"`
+	}
+
+	if requesterId == "" || chatId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId and chatId are required"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	forkId, err := services.ForkChat(requesterId, ownerId, chatId, input.MsgId)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrForkMessageNotFound), err.Error() == "user or chat not found":
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	// The copied history needs a summary of its own before the AI answers in it
+	go helperfuncs.RequestChatTask("summarize", requesterId, forkId)
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "chatId": forkId})
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"github.com/gin-gonic/gin"
+)
+
+func RecordFeedback(c *gin.Context) {
+	userId := c.Param("userId")
+	ownerId := chatOwnerId(c)
+	chatId := c.Param("chatId")
+	msgId := c.Param("msgId")
+
+	var input models.FeedbackInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	feedback, err := services.RecordFeedback(userId, ownerId, chatId, msgId, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFeedbackMessageNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		case errors.Is(err, services.ErrFeedbackNotAIMessage), errors.Is(err, services.ErrInvalidFeedback):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "feedback": feedback})
+}
+
+// ExportFeedback streams feedback as JSON Lines for building evaluation sets.
+// Optional query params: since and until (RFC3339, on updatedAt) and rating.
+func ExportFeedback(c *gin.Context) {
+	var filter services.FeedbackExportFilter
+	for param, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := c.Query(param); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": param + " must be an RFC3339 timestamp"})
+				return
+			}
+			*dst = t
+		}
+	}
+	filter.Rating = c.Query("rating")
+	if filter.Rating != "" && filter.Rating != models.RatingUp && filter.Rating != models.RatingDown {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": services.ErrInvalidRating.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="feedback-%s.jsonl"`, time.Now().UTC().Format("20060102-150405")))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure part-way can only be logged
+	written, err := services.ExportFeedback(c.Request.Context(), c.Writer, filter)
+	if err != nil {
+		log.Printf("[ExportFeedback] stopped after %d record(s): %v", written, err)
+	}
+}
@@ -15,7 +15,7 @@ import (
 
 // Request needs to wait till files are written down in a folder.
 func UploadChatFiles(c *gin.Context) {
-	userId := c.Param("userId")
+	userId := chatOwnerId(c)
 	chatId := c.Param("chatId")
 
 	form, err := c.MultipartForm()
@@ -62,7 +62,7 @@ func UploadChatFiles(c *gin.Context) {
 	}
 
 	// Trigger Service for file uploading
-	uploadSummary := services.HandleFileUpload(userId, chatId, files)
+	uploadSummary := services.HandleFileUpload(userId, chatId, c.GetString("workspaceId"), files)
 
 	var successStatus string
 	var httpStatus int
@@ -94,7 +94,7 @@ func UploadChatFiles(c *gin.Context) {
 
 // Request does not need to wait
 func DeleteChatFiles(c *gin.Context) {
-	userId := c.Param("userId")
+	userId := chatOwnerId(c)
 	chatId := c.Param("chatId")
 
 	var req struct {
@@ -140,7 +140,9 @@ func DeleteChatFiles(c *gin.Context) {
 
 	// Find matching documents filter
 	validEntriesFilter := bson.M{
-		"_id": bson.M{"$in": objectIds},
+		"_id":    bson.M{"$in": objectIds},
+		"userId": userId,
+		"chatId": chatId,
 	}
 
 	toBeDeletedEntries, err := services.FindMany[models.Upload](
@@ -206,7 +208,7 @@ func DeleteChatFiles(c *gin.Context) {
 }
 
 func GetFiles(c *gin.Context) {
-	userId := c.Param("userId")
+	userId := chatOwnerId(c)
 	chatId := c.Param("chatId")
 
 	if userId == "" || chatId == "" {
@@ -225,7 +227,7 @@ func GetFiles(c *gin.Context) {
 }
 
 func SetPersistanceChatFile(c *gin.Context) {
-	userId := c.Param("userId")
+	userId := chatOwnerId(c)
 	chatId := c.Param("chatId")
 	fileId := c.Param("fileId")
 
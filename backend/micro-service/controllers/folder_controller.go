@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"github.com/gin-gonic/gin"
+)
+
+// folderError maps folder and tag service errors onto responses.
+func folderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrFolderNotFound), err.Error() == "user or chat not found":
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, services.ErrFolderExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, services.ErrInvalidFolderName), errors.Is(err, services.ErrInvalidTag),
+		errors.Is(err, services.ErrTooManyTags), err.Error() == "nothing to update":
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+	}
+}
+
+func CreateFolder(c *gin.Context) {
+	userId := c.Param("userId")
+
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	folder, err := services.CreateFolder(userId, input.Name)
+	if err != nil {
+		folderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": folder})
+}
+
+func GetFolders(c *gin.Context) {
+	folders, err := services.ListFolders(c.Param("userId"))
+	if err != nil {
+		folderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": folders})
+}
+
+func RenameFolder(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if err := services.RenameFolder(c.Param("userId"), c.Param("folderId"), input.Name); err != nil {
+		folderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Folder renamed"})
+}
+
+func DeleteFolder(c *gin.Context) {
+	if err := services.DeleteFolder(c.Param("userId"), c.Param("folderId")); err != nil {
+		folderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Folder deleted, its chats are now unfiled"})
+}
+
+func MoveChatToFolder(c *gin.Context) {
+	userId := chatOwnerId(c)
+	chatId := c.Param("chatId")
+
+	// An empty folderId takes the chat out of its folder
+	var input struct {
+		FolderId string `json:"folderId"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if err := services.MoveChatToFolder(userId, chatId, input.FolderId); err != nil {
+		folderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "folderId": input.FolderId})
+}
+
+func UpdateChatTags(c *gin.Context) {
+	userId := chatOwnerId(c)
+	chatId := c.Param("chatId")
+
+	var input models.ChatTagsUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	tags, err := services.UpdateChatTags(userId, chatId, input)
+	if err != nil {
+		folderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "tags": tags})
+}
+
+func GetTags(c *gin.Context) {
+	tags, err := services.ListTags(c.Param("userId"))
+	if err != nil {
+		folderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": tags})
+}
+
+func RenameTag(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	updated, err := services.RenameTag(c.Param("userId"), c.Param("tag"), input.Name)
+	if err != nil {
+		folderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "updatedChats": updated})
+}
+
+func DeleteTag(c *gin.Context) {
+	updated, err := services.DeleteTag(c.Param("userId"), c.Param("tag"))
+	if err != nil {
+		folderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "updatedChats": updated})
+}
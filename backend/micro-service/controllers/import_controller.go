@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ImportChats(c *gin.Context) {
+	userId := c.Param("userId")
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "an export file is required in the 'file' field"})
+		return
+	}
+
+	if fileHeader.Size > services.MaxImportSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Import exceeds %dMB limit (%.2f MB)", services.MaxImportSize/(1024*1024), float64(fileHeader.Size)/(1024*1024)),
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	conversations, err := services.ReadConversationsExport(file, fileHeader.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if len(conversations) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "export contains no conversations"})
+		return
+	}
+
+	importSummary := services.ImportConversations(userId, conversations)
+
+	var successStatus string
+	var httpStatus int
+	// Conversations skipped as already imported count as done
+	switch {
+	case len(importSummary.Failed) == 0:
+		successStatus = "all"
+		httpStatus = http.StatusOK //200
+	case len(importSummary.Failed) == len(conversations):
+		successStatus = "none"
+		httpStatus = http.StatusUnprocessableEntity //422
+	default:
+		successStatus = "partial"
+		httpStatus = http.StatusMultiStatus // 207
+	}
+
+	c.JSON(httpStatus, gin.H{
+		"success":             successStatus,
+		"imported":            importSummary.Imported,
+		"skipped":             importSummary.Skipped,
+		"failed":              importSummary.Failed,
+		"imported_count":      len(importSummary.Imported),
+		"skipped_count":       len(importSummary.Skipped),
+		"failed_count":        len(importSummary.Failed),
+		"total_conversations": len(conversations),
+	})
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"github.com/gin-gonic/gin"
+)
+
+func CreateShareLink(c *gin.Context) {
+	userId := chatOwnerId(c)
+	chatId := c.Param("chatId")
+
+	var input models.ShareLinkInput
+	// The body is optional: no expiry and nothing redacted
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	rawToken, link, err := services.CreateShareLink(userId, chatId, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrShareExpiryPast):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		case err.Error() == "user or chat not found":
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":    true,
+		"message":    "Store this link now, it will not be shown again.",
+		"shareToken": rawToken,
+		"data":       link,
+	})
+}
+
+func GetShareLinks(c *gin.Context) {
+	userId := c.Param("userId")
+
+	links, err := services.ListShareLinks(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": links})
+}
+
+func RevokeShareLink(c *gin.Context) {
+	userId := c.Param("userId")
+	shareId := c.Param("shareId")
+
+	if err := services.RevokeShareLink(userId, shareId); err != nil {
+		if errors.Is(err, services.ErrShareNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Share link revoked"})
+}
+
+// GetSharedChat serves a share link's snapshot without authentication.
+func GetSharedChat(c *gin.Context) {
+	chat, err := services.GetSharedChat(c.Param("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrShareNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"success": true, "chat": chat})
+}
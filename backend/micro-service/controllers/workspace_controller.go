@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"github.com/gin-gonic/gin"
+)
+
+func CreateWorkspace(c *gin.Context) {
+	userId := c.Param("userId")
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId is needed."})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	workspace, err := services.CreateWorkspace(userId, input.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": workspace})
+}
+
+func GetWorkspaces(c *gin.Context) {
+	userId := c.Param("userId")
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId is needed"})
+		return
+	}
+
+	workspaces, err := services.ListWorkspaces(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": workspaces})
+}
+
+func DeleteWorkspace(c *gin.Context) {
+	userId := c.Param("userId")
+	workspaceId := c.Param("workspaceId")
+
+	if userId == "" || workspaceId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId and workspaceId are required"})
+		return
+	}
+
+	if err := services.DeleteWorkspace(userId, workspaceId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Workspace deleted!"})
+}
+
+func SetWorkspaceMember(c *gin.Context) {
+	userId := c.Param("userId")
+	workspaceId := c.Param("workspaceId")
+
+	if userId == "" || workspaceId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId and workspaceId are required"})
+		return
+	}
+
+	var input struct {
+		Email string `json:"email" binding:"required"`
+		Role  string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	member, err := services.SetWorkspaceMember(userId, workspaceId, input.Email, input.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": member})
+}
+
+func RemoveWorkspaceMember(c *gin.Context) {
+	userId := c.Param("userId")
+	workspaceId := c.Param("workspaceId")
+	memberId := c.Param("memberId")
+
+	if userId == "" || workspaceId == "" || memberId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId, workspaceId and memberId are required"})
+		return
+	}
+
+	if err := services.RemoveWorkspaceMember(userId, workspaceId, memberId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Member removed"})
+}
+
+func AddChatToWorkspace(c *gin.Context) {
+	userId := c.Param("userId")
+	workspaceId := c.Param("workspaceId")
+	chatId := c.Param("chatId")
+
+	if userId == "" || workspaceId == "" || chatId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId, workspaceId and chatId are required"})
+		return
+	}
+
+	if err := services.AddChatToWorkspace(userId, workspaceId, chatId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Chat shared with workspace"})
+}
+
+func RemoveChatFromWorkspace(c *gin.Context) {
+	userId := c.Param("userId")
+	workspaceId := c.Param("workspaceId")
+	chatId := c.Param("chatId")
+
+	if userId == "" || workspaceId == "" || chatId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "userId, workspaceId and chatId are required"})
+		return
+	}
+
+	if err := services.RemoveChatFromWorkspace(userId, workspaceId, chatId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Chat removed from workspace"})
+}
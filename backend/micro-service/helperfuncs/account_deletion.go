@@ -0,0 +1,138 @@
+package helperfuncs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Guards against running the same user's deletion twice in this process.
+var runningDeletions sync.Map
+
+type deletionStep struct {
+	name string
+	run  func(userId string) error
+}
+
+// Ordered so that a failure leaves the user able to log in and retry:
+// sessions are revoked last.
+var deletionSteps = []deletionStep{
+	{"files_and_vectors", deleteUserFilesAndVectors},
+	{"upload_dir", services.RemoveUserUploadDir},
+	{"redis_chats", services.DeleteUserRedisChats},
+	{"chats", services.DeleteUserChats},
+	{"feedback", services.DeleteUserFeedback},
+	{"share_links", services.DeleteUserShareLinks},
+	{"folders", services.DeleteUserFolders},
+	{"workspaces", services.LeaveAllWorkspaces},
+	{"api_keys", services.DeleteUserAPIKeys},
+	{"user", services.DeleteUserRecord},
+	{"sessions", func(userId string) error {
+		_, err := services.LogoutEverywhere(userId)
+		return err
+	}},
+}
+
+// RunAccountDeletion executes every step not yet recorded on the job, stopping at
+// the first failure. Calling it again with the same job resumes from that step.
+func RunAccountDeletion(job *models.DeletionJob) {
+	if _, busy := runningDeletions.LoadOrStore(job.UserId, true); busy {
+		log.Printf("[RunAccountDeletion] Deletion already running for userId=%s", job.UserId)
+		return
+	}
+	defer runningDeletions.Delete(job.UserId)
+
+	for _, step := range deletionSteps {
+		if slices.Contains(job.CompletedSteps, step.name) {
+			continue
+		}
+
+		log.Printf("[RunAccountDeletion] START step=%s userId=%s jobId=%s", step.name, job.UserId, job.ID.Hex())
+		if err := step.run(job.UserId); err != nil {
+			log.Printf("[RunAccountDeletion] ERROR step=%s userId=%s: %v", step.name, job.UserId, err)
+			if err := services.MarkDeletionJobFailed(job.ID, step.name, err); err != nil {
+				log.Printf("[RunAccountDeletion] ERROR recording failure for jobId=%s: %v", job.ID.Hex(), err)
+			}
+			return
+		}
+
+		if err := services.MarkDeletionStepDone(job.ID, step.name); err != nil {
+			log.Printf("[RunAccountDeletion] ERROR recording step=%s for jobId=%s: %v", step.name, job.ID.Hex(), err)
+			return
+		}
+	}
+
+	if err := services.MarkDeletionJobCompleted(job.ID); err != nil {
+		log.Printf("[RunAccountDeletion] ERROR completing jobId=%s: %v", job.ID.Hex(), err)
+		return
+	}
+	log.Printf("[RunAccountDeletion] COMPLETED userId=%s jobId=%s", job.UserId, job.ID.Hex())
+}
+
+// ResumeDeletionJobs restarts deletion jobs left running by a crash. Failed jobs wait
+// for an operator to resume them through POST /admin/deletionJobs/:userId.
+func ResumeDeletionJobs() {
+	jobs, err := services.FindInterruptedDeletionJobs()
+	if err != nil {
+		log.Printf("[ResumeDeletionJobs] ERROR loading jobs: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		resumed, err := services.StartDeletionJob(job.UserId)
+		if err != nil {
+			log.Printf("[ResumeDeletionJobs] ERROR restarting job for userId=%s: %v", job.UserId, err)
+			continue
+		}
+		RunAccountDeletion(resumed)
+	}
+}
+
+// deleteUserFilesAndVectors removes files from disk and raises vectorize_file delete
+// tasks, then waits for the AI service to drop the Upload rows once vectors are gone.
+func deleteUserFilesAndVectors(userId string) error {
+	uploads, err := services.FindMany[models.Upload](os.Getenv("FILE_COLLECTION"), bson.M{"userId": userId})
+	if err != nil {
+		return err
+	}
+	if len(uploads) == 0 {
+		return nil
+	}
+
+	byChat := make(map[string][]models.Upload)
+	for _, u := range uploads {
+		byChat[u.ChatId] = append(byChat[u.ChatId], u)
+	}
+
+	services.HandleFilesDelete(uploads)
+	for chatId, chatUploads := range byChat {
+		CarryVectorDocsDeletionTask(userId, chatId, chatUploads)
+	}
+
+	wait := 2 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("VECTOR_DELETE_WAIT")); err == nil && d > 0 {
+		wait = d
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		remaining, err := services.CountUserUploads(userId)
+		if err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d upload(s) still waiting for vector deletion", remaining)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -87,3 +87,33 @@ func CarryVectorDocsDeletionTask(userId, chatId string, delFiles []models.Upload
 
 	}
 }
+
+// ChatTask asks the AI service to do background work on a chat, e.g. "summarize".
+type ChatTask struct {
+	Operation string `json:"operation"`
+	UserID    string `json:"userId"`
+	ChatID    string `json:"chatId"`
+}
+
+func RequestChatTask(operation, userId, chatId string) {
+	task := ChatTask{
+		Operation: operation,
+		UserID:    userId,
+		ChatID:    chatId,
+	}
+
+	// Marshal to JSON
+	taskBytes, err := json.Marshal(task)
+	if err != nil {
+		log.Printf("[RequestChatTask (Kafka Publisher)] ERROR marshalling %s task for chat=%s: %v", operation, chatId, err)
+		return
+	}
+
+	//Pubish; key matches server_reply so a chat's tasks stay ordered
+	err = publisherHandler.SendMessage("chat_tasks", userId+"_"+chatId, taskBytes)
+	if err != nil {
+		log.Printf("[RequestChatTask (Kafka Publisher)] ERROR sending %s task for chat=%s: %v", operation, chatId, err)
+	} else {
+		log.Printf("[RequestChatTask (Kafka Publisher)] %s task sent for userId=%s chatId=%s", operation, userId, chatId)
+	}
+}
@@ -0,0 +1,66 @@
+package helperfuncs
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Chats purged per pass; the rest wait for the next tick.
+const trashPurgeBatch = 100
+
+// StartTrashPurger permanently removes chats that have been in the trash longer than
+// services.TrashRetention, checking every TRASH_PURGE_INTERVAL (default 1h).
+func StartTrashPurger() {
+	interval := time.Hour
+	if d, err := time.ParseDuration(os.Getenv("TRASH_PURGE_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purgeExpiredTrash()
+		<-ticker.C
+	}
+}
+
+func purgeExpiredTrash() {
+	cutoff := time.Now().Add(-services.TrashRetention())
+	chats, err := services.ExpiredTrash(cutoff, trashPurgeBatch)
+	if err != nil {
+		log.Printf("[TrashPurger] ERROR loading expired chats: %v", err)
+		return
+	}
+
+	for _, chat := range chats {
+		if err := PurgeTrashedChat(chat.UserId, chat.ChatId); err != nil {
+			log.Printf("[TrashPurger] ERROR purging userId=%s chatId=%s: %v", chat.UserId, chat.ChatId, err)
+			continue
+		}
+		log.Printf("[TrashPurger] Purged userId=%s chatId=%s (deleted %s)", chat.UserId, chat.ChatId, chat.DeletedAt.Format(time.RFC3339))
+	}
+}
+
+// PurgeTrashedChat removes a trashed chat for good: its files on disk, its vectors
+// (the AI service drops the Upload rows along with them), its Redis hash, its
+// messages and finally the chat document.
+func PurgeTrashedChat(userId, chatId string) error {
+	uploads, err := services.FindMany[models.Upload](os.Getenv("FILE_COLLECTION"), bson.M{"userId": userId, "chatId": chatId})
+	if err != nil {
+		return err
+	}
+	if len(uploads) > 0 {
+		services.HandleFilesDelete(uploads)
+		CarryVectorDocsDeletionTask(userId, chatId, uploads)
+	}
+
+	if err := services.RemoveChatUploadDir(userId, chatId); err != nil {
+		return err
+	}
+	return services.PurgeChat(userId, chatId)
+}
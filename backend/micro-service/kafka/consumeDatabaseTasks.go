@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/IBM/sarama"
 	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -18,13 +21,6 @@ type dbRequest struct {
 	Action string `json:"action"` // "flush" or "del"
 }
 
-type Message struct {
-	MsgID     string `json:"msgId" bson:"msgId"`
-	Timestamp string `json:"timestamp" bson:"timestamp"`
-	Role      string `json:"role" bson:"role"`
-	Content   string `json:"content" bson:"content"`
-}
-
 // StartDbopsConsumer starts consuming the dbops topic
 func StartDbopsConsumer(brokers []string, topic, groupId string) {
 	config := sarama.NewConfig()
@@ -75,7 +71,7 @@ func (h *dbopsTaskConsumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession
 			}
 
 			// Parse Redis values
-			var messages []Message
+			var messages []models.Message
 			if rawMsgs, ok := vals["messages"]; ok && rawMsgs != "" {
 				_ = json.Unmarshal([]byte(rawMsgs), &messages)
 			}
@@ -95,11 +91,11 @@ func (h *dbopsTaskConsumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession
 				continue
 			}
 
-			// Clear only the `messages` field in Redis (keep summary for next cycle)
-			if err := config.RedisClient.HSet(context.Background(), KEY, "messages", "[]").Err(); err != nil {
-				log.Printf("🔴 Redis HSet failed while clearing messages: %v", err)
+			// Clear the flushed messages from Redis (keep summary for next cycle)
+			if _, err := services.SettleFlushedMessages(context.Background(), task.UserId, task.ChatId, messages, false); err != nil {
+				log.Printf("🔴 Failed to clear flushed messages from Redis: %v", err)
 			} else {
-				log.Printf("🗑️  Redis field cleared: %s.messages = []", KEY)
+				log.Printf("🗑️  Redis field cleared: %s.messages", KEY)
 			}
 
 		case "del":
@@ -113,7 +109,7 @@ func (h *dbopsTaskConsumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession
 				continue
 			}
 
-			var messages []Message
+			var messages []models.Message
 			if rawMsgs, ok := vals["messages"]; ok && rawMsgs != "" {
 				_ = json.Unmarshal([]byte(rawMsgs), &messages)
 			}
@@ -130,11 +126,16 @@ func (h *dbopsTaskConsumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession
 				log.Printf("✅ Flushed chat to DB before deletion (userId=%s, chatId=%s)", task.UserId, task.ChatId)
 			}
 
-			// Safe to delete Redis key after successful flush
-			if err := config.RedisClient.Del(context.Background(), KEY).Err(); err != nil {
-				log.Printf("❌ Failed to delete Redis key: %v", err)
-			} else {
+			// Delete the key only if nothing arrived since the read; otherwise another
+			// viewer is still chatting and the next flush picks those messages up
+			dropped, err := services.SettleFlushedMessages(context.Background(), task.UserId, task.ChatId, messages, true)
+			switch {
+			case err != nil:
+				log.Printf("❌ Failed to delete Redis key %s: %v", KEY, err)
+			case dropped:
 				log.Printf("✅ Deleted Redis key: %s", KEY)
+			default:
+				log.Printf("🟡 Kept Redis key %s, it has messages newer than the flush", KEY)
 			}
 
 		default:
@@ -146,8 +147,14 @@ func (h *dbopsTaskConsumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession
 	return nil
 }
 
-func AddMessagesToChat(ctx context.Context, userId, chatId, summary string, messages []Message) error {
-	collection := config.GetCollection("chats")
+func AddMessagesToChat(ctx context.Context, userId, chatId, summary string, messages []models.Message) error {
+	collection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	// Messages go to their own collection; the chat doc only carries the summary
+	added, err := services.AppendChatMessages(ctx, userId, chatId, messages)
+	if err != nil {
+		return err
+	}
 
 	filter := bson.M{
 		"userId": userId,
@@ -155,9 +162,6 @@ func AddMessagesToChat(ctx context.Context, userId, chatId, summary string, mess
 	}
 
 	update := bson.M{
-		"$addToSet": bson.M{
-			"messages": bson.M{"$each": messages},
-		},
 		"$set": bson.M{
 			"summary": summary,
 		},
@@ -172,7 +176,7 @@ func AddMessagesToChat(ctx context.Context, userId, chatId, summary string, mess
 	}
 
 	if result.MatchedCount > 0 {
-		log.Printf("✅ Matched %d doc(s), Modified %d", result.MatchedCount, result.ModifiedCount)
+		log.Printf("✅ Stored %d new message(s), Matched %d doc(s), Modified %d", added, result.MatchedCount, result.ModifiedCount)
 	} else if result.UpsertedCount > 0 {
 		log.Printf("🆕 Created new chat doc with _id=%v", result.UpsertedID)
 	}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"expvar"
 	"log"
 	"time"
 
 	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
 	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/controllers"
+	helperfuncs "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/helperfuncs"
 	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/kafka"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/middleware"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -21,12 +26,37 @@ func init() {
 
 	config.ConnectDB("nextjs_gpt_chat")
 	config.ConnectRedis()
+	config.InitMailer()
+
+	if err := services.EnsureMessageIndexes(); err != nil {
+		log.Fatalf("Failed to create message indexes: %v", err)
+	}
+	if err := services.EnsureSearchIndexes(); err != nil {
+		log.Fatalf("Failed to create search indexes: %v", err)
+	}
+	if err := services.EnsureFeedbackIndexes(); err != nil {
+		log.Fatalf("Failed to create feedback indexes: %v", err)
+	}
+	if err := services.EnsureShareIndexes(); err != nil {
+		log.Fatalf("Failed to create share indexes: %v", err)
+	}
+	if err := services.EnsureFolderIndexes(); err != nil {
+		log.Fatalf("Failed to create folder indexes: %v", err)
+	}
+	if err := services.EnsureImportIndexes(); err != nil {
+		log.Fatalf("Failed to create import indexes: %v", err)
+	}
+	if err := services.EnsureAPIKeyIndexes(); err != nil {
+		log.Fatalf("Failed to create api key indexes: %v", err)
+	}
 }
 
 func main() {
 	var brokers = []string{"localhost:9092"}
 
 	go kafka.StartDbopsConsumer(brokers, "db_ops", "dp_ops_group")
+	go helperfuncs.ResumeDeletionJobs()
+	go helperfuncs.StartTrashPurger()
 
 	r := gin.Default()
 
@@ -43,26 +73,93 @@ func main() {
 	// Auth Services
 	r.POST("/registerUser", controllers.RegisterUser)
 	r.POST("/validateUser", controllers.ValidateUser)
+	r.POST("/refresh", controllers.RefreshToken)
+	r.POST("/logout", controllers.Logout)
+	r.POST("/requestPasswordReset", controllers.RequestPasswordReset)
+	r.POST("/resetPassword", controllers.ResetPassword)
+	r.GET("/verifyEmail", controllers.VerifyEmail)
+
+	// Public read-only chat snapshots
+	r.GET("/shared/:token", controllers.GetSharedChat)
+
+	// Operator routes, guarded by X-Admin-Token
+	admin := r.Group("/admin")
+	admin.Use(middleware.RequireAdmin())
+	admin.POST("/unlockLogin", controllers.UnlockLogin)
+	admin.GET("/metrics", gin.WrapH(expvar.Handler()))
+	admin.GET("/deletionJobs/:userId", controllers.GetDeletionJob)
+	admin.POST("/deletionJobs/:userId", controllers.DeleteAccount) // resume a failed job
+	admin.GET("/feedback/export", controllers.ExportFeedback)
+
+	// Everything below requires a bearer token (access token or API key) whose subject matches :userId
+	auth := r.Group("/")
+	auth.Use(middleware.RequireAuth())
+
+	auth.POST("/logoutAll/:userId", middleware.RequireSession(), controllers.LogoutEverywhere)
+	auth.POST("/resendVerification/:userId", middleware.RequireSession(), controllers.ResendVerificationEmail)
+
+	// Account deletion (login session only)
+	auth.DELETE("/users/:userId", middleware.RequireSession(), controllers.DeleteAccount)
+	auth.GET("/users/:userId/deletion", middleware.RequireSession(), controllers.GetDeletionJob)
+
+	// API key management (login session only)
+	auth.POST("/apiKeys/:userId", middleware.RequireSession(), controllers.CreateAPIKey)
+	auth.GET("/apiKeys/:userId", middleware.RequireSession(), controllers.GetAPIKeys)
+	auth.DELETE("/apiKeys/:userId/:keyId", middleware.RequireSession(), controllers.RevokeAPIKey)
+
+	// Share link management
+	auth.GET("/shares/:userId", middleware.RequireScope(services.ScopeChatsRead), controllers.GetShareLinks)
+	auth.DELETE("/shares/:userId/:shareId", middleware.RequireScope(services.ScopeChatsWrite), controllers.RevokeShareLink)
+
+	// Workspace Routes
+	auth.POST("/workspaces/:userId", middleware.RequireScope(services.ScopeChatsWrite), controllers.CreateWorkspace)
+	auth.GET("/workspaces/:userId", middleware.RequireScope(services.ScopeChatsRead), controllers.GetWorkspaces)
+	auth.DELETE("/workspaces/:userId/:workspaceId", middleware.RequireScope(services.ScopeChatsWrite), controllers.DeleteWorkspace)
+	auth.POST("/workspaces/:userId/:workspaceId/members", middleware.RequireScope(services.ScopeChatsWrite), controllers.SetWorkspaceMember)
+	auth.DELETE("/workspaces/:userId/:workspaceId/members/:memberId", middleware.RequireScope(services.ScopeChatsWrite), controllers.RemoveWorkspaceMember)
+	auth.POST("/workspaces/:userId/:workspaceId/chats/:chatId", middleware.RequireScope(services.ScopeChatsWrite), controllers.AddChatToWorkspace)
+	auth.DELETE("/workspaces/:userId/:workspaceId/chats/:chatId", middleware.RequireScope(services.ScopeChatsWrite), controllers.RemoveChatFromWorkspace)
+
+	// Folder and tag Routes
+	auth.POST("/folders/:userId", middleware.RequireScope(services.ScopeChatsWrite), controllers.CreateFolder)
+	auth.GET("/folders/:userId", middleware.RequireScope(services.ScopeChatsRead), controllers.GetFolders)
+	auth.PATCH("/folders/:userId/:folderId", middleware.RequireScope(services.ScopeChatsWrite), controllers.RenameFolder)
+	auth.DELETE("/folders/:userId/:folderId", middleware.RequireScope(services.ScopeChatsWrite), controllers.DeleteFolder)
+	auth.GET("/tags/:userId", middleware.RequireScope(services.ScopeChatsRead), controllers.GetTags)
+	auth.PATCH("/tags/:userId/:tag", middleware.RequireScope(services.ScopeChatsWrite), controllers.RenameTag)
+	auth.DELETE("/tags/:userId/:tag", middleware.RequireScope(services.ScopeChatsWrite), controllers.DeleteTag)
 
 	// Debug Route
-	r.GET("/history/:userId", controllers.Debug)
+	auth.GET("/history/:userId", middleware.RequireScope(services.ScopeChatsRead), controllers.Debug)
 
 	// Chat Routes
-	r.POST("/createChat/:userId", controllers.CreateChat)
-	r.DELETE("/deleteChat/:userId/:chatId", controllers.DeleteChat)
-	r.GET("/chatHeads/:userId", controllers.GetChatHeads)
-	r.GET("/chats/:userId/:chatId", controllers.GetChatMessages)
+	auth.POST("/createChat/:userId", middleware.RequireScope(services.ScopeChatsWrite), controllers.CreateChat)
+	auth.POST("/importChats/:userId", middleware.RequireScope(services.ScopeChatsWrite), controllers.ImportChats)
+	auth.DELETE("/deleteChat/:userId/:chatId", middleware.RequireScope(services.ScopeChatsWrite), middleware.RequireChatRole(models.RoleOwner), controllers.DeleteChat)
+	auth.GET("/trash/:userId", middleware.RequireScope(services.ScopeChatsRead), controllers.GetTrashedChats)
+	auth.POST("/trash/:userId/:chatId/restore", middleware.RequireScope(services.ScopeChatsWrite), controllers.RestoreChat)
+	auth.GET("/chatHeads/:userId", middleware.RequireScope(services.ScopeChatsRead), controllers.GetChatHeads)
+	auth.GET("/search/:userId", middleware.RequireScope(services.ScopeChatsRead), controllers.SearchChats)
+	auth.GET("/chats/:userId/:chatId", middleware.RequireScope(services.ScopeChatsRead), middleware.RequireChatRole(models.RoleViewer), controllers.GetChatMessages)
+	auth.GET("/chats/:userId/:chatId/export", middleware.RequireScope(services.ScopeChatsRead), middleware.RequireChatRole(models.RoleViewer), controllers.ExportChat)
+	auth.POST("/chats/:userId/:chatId/share", middleware.RequireScope(services.ScopeChatsWrite), middleware.RequireChatRole(models.RoleOwner), controllers.CreateShareLink)
+	auth.POST("/chats/:userId/:chatId/fork", middleware.RequireScope(services.ScopeChatsWrite), middleware.RequireChatRole(models.RoleViewer), controllers.ForkChat)
+	auth.PUT("/chats/:userId/:chatId/folder", middleware.RequireScope(services.ScopeChatsWrite), middleware.RequireChatRole(models.RoleOwner), controllers.MoveChatToFolder)
+	auth.PATCH("/chats/:userId/:chatId/tags", middleware.RequireScope(services.ScopeChatsWrite), middleware.RequireChatRole(models.RoleEditor), controllers.UpdateChatTags)
+	auth.PATCH("/chats/:userId/:chatId", middleware.RequireScope(services.ScopeChatsWrite), middleware.RequireChatRole(models.RoleEditor), controllers.UpdateChat)
+	auth.PUT("/chats/:userId/:chatId/messages/:msgId/feedback", middleware.RequireScope(services.ScopeChatsWrite), middleware.RequireChatRole(models.RoleViewer), controllers.RecordFeedback)
+	auth.GET("/chatAccess/:userId/:chatId", middleware.RequireScope(services.ScopeQuery), middleware.RequireChatRole(models.RoleViewer), controllers.CheckChatAccess)
 
-	r.POST("/addMemory/:userId/:chatId", controllers.AddChatMemory)
-	r.GET("/memories/:userId/:chatId", controllers.GetChatMemories)
-	r.DELETE("/deleteMemory/:userId/:chatId/:memId", controllers.DeleteChatMemory)
-	r.POST("/setMemoryPersist/:userId/:chatId/:memId", controllers.SetPersistanceChatMemory)
+	auth.POST("/addMemory/:userId/:chatId", middleware.RequireScope(services.ScopeMemoriesWrite), middleware.RequireChatRole(models.RoleEditor), controllers.AddChatMemory)
+	auth.GET("/memories/:userId/:chatId", middleware.RequireScope(services.ScopeMemoriesRead), middleware.RequireChatRole(models.RoleViewer), controllers.GetChatMemories)
+	auth.DELETE("/deleteMemory/:userId/:chatId/:memId", middleware.RequireScope(services.ScopeMemoriesWrite), middleware.RequireChatRole(models.RoleEditor), controllers.DeleteChatMemory)
+	auth.POST("/setMemoryPersist/:userId/:chatId/:memId", middleware.RequireScope(services.ScopeMemoriesWrite), middleware.RequireChatRole(models.RoleEditor), controllers.SetPersistanceChatMemory)
 
 	// File upload and deletion Routes
-	r.GET("/getFilesData/:userId/:chatId", controllers.GetFiles)
-	r.POST("/uploadFiles/:userId/:chatId", controllers.UploadChatFiles)
-	r.DELETE("/deleteFiles/:userId/:chatId", controllers.DeleteChatFiles)
-	r.POST("/setFilePersist/:userId/:chatId/:fileId", controllers.SetPersistanceChatFile)
+	auth.GET("/getFilesData/:userId/:chatId", middleware.RequireScope(services.ScopeFilesRead), middleware.RequireChatRole(models.RoleViewer), controllers.GetFiles)
+	auth.POST("/uploadFiles/:userId/:chatId", middleware.RequireScope(services.ScopeFilesWrite), middleware.RequireChatRole(models.RoleEditor), controllers.UploadChatFiles)
+	auth.DELETE("/deleteFiles/:userId/:chatId", middleware.RequireScope(services.ScopeFilesWrite), middleware.RequireChatRole(models.RoleEditor), controllers.DeleteChatFiles)
+	auth.POST("/setFilePersist/:userId/:chatId/:fileId", middleware.RequireScope(services.ScopeFilesWrite), middleware.RequireChatRole(models.RoleEditor), controllers.SetPersistanceChatFile)
 
 	r.Run(":8080")
 
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin guards operator routes with the shared ADMIN_TOKEN, sent as X-Admin-Token.
+// With no ADMIN_TOKEN configured the admin routes are disabled.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := os.Getenv("ADMIN_TOKEN")
+		given := c.GetHeader("X-Admin-Token")
+
+		if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(given)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "error": "admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
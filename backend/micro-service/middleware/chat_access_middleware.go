@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireChatRole resolves the authenticated user's role on :chatId (as creator or
+// through a workspace) and exposes the chat's owner to the controllers as "chatOwnerId".
+// Must run after RequireAuth.
+func RequireChatRole(minRole string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access, err := services.AuthorizeChat(c.GetString("userId"), c.Param("chatId"), minRole)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrChatAccessDenied):
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+			case errors.Is(err, services.ErrInsufficientRole):
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+			}
+			return
+		}
+
+		c.Set("chatOwnerId", access.OwnerId)
+		c.Set("workspaceId", access.WorkspaceId)
+		c.Set("chatRole", access.Role)
+		c.Next()
+	}
+}
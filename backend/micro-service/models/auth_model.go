@@ -1,9 +1,40 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type User struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Email    string             `bson:"email" json:"email"`
-	Password string             `bson:"password" json:"password"`
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email           string             `bson:"email" json:"email"`
+	Password        string             `bson:"password" json:"-"`
+	EmailVerified   bool               `bson:"emailVerified" json:"emailVerified"`
+	EmailVerifiedAt *time.Time         `bson:"emailVerifiedAt,omitempty" json:"emailVerifiedAt,omitempty"`
+}
+
+type APIKey struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserId       string             `bson:"userId" json:"userId"`
+	Name         string             `bson:"name" json:"name"`
+	Prefix       string             `bson:"prefix" json:"prefix"` // first characters of the key, for display only
+	KeyHash      string             `bson:"keyHash" json:"-"`
+	Scopes       []string           `bson:"scopes" json:"scopes"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	LastUsedAt   *time.Time         `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	RequestCount int64              `bson:"requestCount" json:"requestCount"`
+	RevokedAt    *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// DeletionJob tracks a cascading account deletion so it can resume after a failure.
+type DeletionJob struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserId         string             `bson:"userId" json:"userId"`
+	Status         string             `bson:"status" json:"status"` // "running", "failed" or "completed"
+	CompletedSteps []string           `bson:"completedSteps" json:"completedSteps"`
+	LastError      string             `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
@@ -6,11 +6,51 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ThreadRoot is the ParentId of a message that starts a chat.
+const ThreadRoot = "root"
+
+// DefaultChatName is given to chats created without a name; the AI service
+// replaces it with a generated title after the first exchange.
+const DefaultChatName = "New Chat"
+
 type Message struct {
 	MsgID     string `json:"msgId" bson:"msgId"`
 	Role      string `json:"role" bson:"role"`
 	Content   string `json:"content" bson:"content"`
 	Timestamp string `json:"timestamp" bson:"timestamp"`
+	// ParentId is the message this one follows. Siblings sharing a parent are
+	// variants (edits or regenerated replies); all but one are Inactive. Messages
+	// stored before branching existed have no ParentId and follow the one before them.
+	ParentId string `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	Inactive bool   `json:"inactive,omitempty" bson:"inactive,omitempty"`
+	// Generation records what produced an AI message; nil on user messages.
+	Generation *Generation `json:"generation,omitempty" bson:"generation,omitempty"`
+}
+
+// Generation is the model, prompt and retrieved context an AI reply was produced with.
+type Generation struct {
+	Model         string            `json:"model" bson:"model"`
+	PromptVersion string            `json:"promptVersion" bson:"promptVersion"`
+	Sources       []RetrievedSource `json:"sources,omitempty" bson:"sources,omitempty"`
+	FileIds       []string          `json:"fileIds,omitempty" bson:"fileIds,omitempty"`
+	MemIds        []string          `json:"memIds,omitempty" bson:"memIds,omitempty"`
+}
+
+// RetrievedSource is one document chunk returned by the vector search for a reply.
+type RetrievedSource struct {
+	Source   string  `json:"source" bson:"source"`
+	Page     int32   `json:"page" bson:"page"`
+	Distance float32 `json:"distance" bson:"distance"`
+}
+
+// ChatMessage is a Message as stored in the messages collection, ordered by Seq within its chat.
+type ChatMessage struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserId    string             `bson:"userId" json:"userId"`
+	ChatId    string             `bson:"chatId" json:"chatId"`
+	Seq       int64              `bson:"seq" json:"seq"`
+	CreatedAt time.Time          `bson:"createdAt" json:"-"` // parsed Timestamp, for time-based cursors
+	Message   `bson:",inline"`
 }
 
 type Memory struct {
@@ -21,24 +61,72 @@ type Memory struct {
 }
 
 type ChatMessages struct {
-	Messages map[string]Message `json:"messages"`
-	Order    []string           `json:"order"`
-	UserId   string             `json:"userId"`
-	ChatId   string             `json:"chatId"`
+	Messages   map[string]Message `json:"messages"`
+	Order      []string           `json:"order"`
+	UserId     string             `json:"userId"`
+	ChatId     string             `json:"chatId"`
+	HasMore    bool               `json:"hasMore"`
+	NextCursor string             `json:"nextCursor,omitempty"` // pass back as before (or after) to continue
 }
 
 type Chat struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserId    string             `bson:"userId" json:"userId"`
-	ChatId    string             `bson:"chatId" json:"chatId"`
-	Name      string             `bson:"name" json:"name"`
-	Messages  []Message          `bson:"messages" json:"messages"`
-	Memory    []Memory           `bson:"memory,omitempty" json:"memory,omitempty"`
-	CreatedAt time.Time          `bson:"createdAt" json:"createdAt,omitempty"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserId      string             `bson:"userId" json:"userId"` // creator; chat data is keyed on it
+	ChatId      string             `bson:"chatId" json:"chatId"`
+	WorkspaceId string             `bson:"workspaceId,omitempty" json:"workspaceId,omitempty"`
+	Name        string             `bson:"name" json:"name"`
+	Messages    []Message          `bson:"messages,omitempty" json:"messages,omitempty"` // legacy embedded history; see ChatMessage
+	MessageSeq  int64              `bson:"messageSeq,omitempty" json:"-"`                // last ChatMessage.Seq handed out
+	Memory      []Memory           `bson:"memory,omitempty" json:"memory,omitempty"`
+	ForkedFrom  *ForkOrigin        `bson:"forkedFrom,omitempty" json:"forkedFrom,omitempty"`
+	Pinned      bool               `bson:"pinned,omitempty" json:"pinned"`
+	Archived    bool               `bson:"archived,omitempty" json:"archived"`
+	FolderId    string             `bson:"folderId,omitempty" json:"folderId,omitempty"` // one of the owner's Folders
+	Tags        []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	// Maintained by the flush consumer so heads never touch message bodies
+	LastMessage  string     `bson:"lastMessage,omitempty" json:"lastMessage,omitempty"` // truncated preview
+	MessageCount int64      `bson:"messageCount" json:"messageCount"`
+	UpdatedAt    time.Time  `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"` // time of the latest message
+	CreatedAt    time.Time  `bson:"createdAt" json:"createdAt,omitempty"`
+	DeletedAt    *time.Time `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"` // set while the chat is in the trash
+
+	// ImportSourceId is the conversation id of an imported chat in its export, so
+	// importing the same export again skips it
+	ImportSourceId string `bson:"importSourceId,omitempty" json:"importSourceId,omitempty"`
+}
+
+// TrashedChat is a chat waiting in its owner's trash to be purged.
+type TrashedChat struct {
+	ChatId    string    `bson:"chatId" json:"chatId"`
+	Name      string    `bson:"name" json:"name"`
+	DeletedAt time.Time `bson:"deletedAt" json:"deletedAt"`
+	PurgeAt   time.Time `bson:"-" json:"purgeAt"`
+}
+
+// ForkOrigin records the chat and message a fork was branched from.
+type ForkOrigin struct {
+	UserId string `bson:"userId" json:"userId"`
+	ChatId string `bson:"chatId" json:"chatId"`
+	MsgId  string `bson:"msgId" json:"msgId"`
+}
+
+// ChatUpdate is a partial update of a chat's metadata; nil fields are left untouched.
+type ChatUpdate struct {
+	Name     *string `json:"name"`
+	Pinned   *bool   `json:"pinned"`
+	Archived *bool   `json:"archived"`
 }
 
 type ChatHeads struct {
-	ChatId  string `bson:"chatId" json:"chatId"`
-	Name    string `bson:"name" json:"name"`
-	Preview string `bson:"preview" json:"preview"`
+	ChatId       string    `bson:"chatId" json:"chatId"`
+	OwnerId      string    `bson:"userId" json:"ownerId"`
+	WorkspaceId  string    `bson:"workspaceId,omitempty" json:"workspaceId,omitempty"`
+	Name         string    `bson:"name" json:"name"`
+	Pinned       bool      `bson:"pinned" json:"pinned"`
+	Archived     bool      `bson:"archived" json:"archived"`
+	FolderId     string    `bson:"folderId,omitempty" json:"folderId,omitempty"`
+	Tags         []string  `bson:"tags" json:"tags"`
+	Preview      string    `bson:"preview" json:"preview"`
+	MessageCount int64     `bson:"messageCount" json:"messageCount"`
+	UpdatedAt    time.Time `bson:"updatedAt" json:"updatedAt"`
 }
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ChatExport is everything a chat export renders, in display order.
+type ChatExport struct {
+	ChatId     string         `json:"chatId" bson:"chatId"`
+	Name       string         `json:"name" bson:"name"`
+	CreatedAt  time.Time      `json:"createdAt" bson:"createdAt"`
+	ExportedAt time.Time      `json:"exportedAt" bson:"exportedAt"`
+	Messages   []Message      `json:"messages" bson:"messages"`
+	Memories   []Memory       `json:"memories" bson:"memories"`
+	Files      []ExportedFile `json:"files" bson:"files"`
+}
+
+type ExportedFile struct {
+	FileName  string    `json:"fileName" bson:"fileName"`
+	FileType  string    `json:"fileType" bson:"fileType"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	Status    string    `json:"status" bson:"status"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Feedback ratings.
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+)
+
+// Feedback is one user's rating of an AI message. It keeps a snapshot of the
+// exchange and of what produced the reply, so it stays usable as an evaluation
+// record after the chat changes.
+type Feedback struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"feedbackId"`
+	UserId     string             `bson:"userId" json:"userId"`   // who rated
+	OwnerId    string             `bson:"ownerId" json:"ownerId"` // whom the chat is stored under
+	ChatId     string             `bson:"chatId" json:"chatId"`
+	MsgId      string             `bson:"msgId" json:"msgId"`
+	Rating     string             `bson:"rating" json:"rating"`
+	Reason     string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Tags       []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	Query      string             `bson:"query" json:"query"`       // user message the reply answered
+	Response   string             `bson:"response" json:"response"` // the rated reply
+	Generation *Generation        `bson:"generation,omitempty" json:"generation,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+type FeedbackInput struct {
+	Rating string   `json:"rating" binding:"required"`
+	Reason string   `json:"reason"`
+	Tags   []string `json:"tags"`
+}
@@ -10,6 +10,7 @@ type Upload struct {
 	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	UserId            string             `bson:"userId" json:"userId"`
 	ChatId            string             `bson:"chatId" json:"chatId"`
+	WorkspaceId       string             `bson:"workspaceId,omitempty" json:"workspaceId,omitempty"`
 	FileName          string             `bson:"fileName" json:"fileName"`
 	FileType          string             `bson:"fileType" json:"fileType"`
 	Path              string             `bson:"path" json:"path"`
@@ -18,4 +19,9 @@ type Upload struct {
 	Status            string             `bson:"status" json:"status"`
 	Error             string             `bson:"error" json:"error"`
 	Persist           bool               `bson:"persist" json:"persist"`
+	// Set on uploads re-linked into a forked chat: the original upload whose
+	// stored file and vectors this entry shares. Empty for regular uploads.
+	SourceFileId string `bson:"sourceFileId,omitempty" json:"sourceFileId,omitempty"`
+	SourceUserId string `bson:"sourceUserId,omitempty" json:"-"`
+	SourceChatId string `bson:"sourceChatId,omitempty" json:"-"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Folder groups a user's chats; a chat is in at most one folder.
+type Folder struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserId    string             `bson:"userId" json:"userId"`
+	Name      string             `bson:"name" json:"name"`
+	ChatCount int64              `bson:"-" json:"chatCount"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// ChatTagsUpdate adds and removes tags on a chat in one atomic update.
+type ChatTagsUpdate struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// TagCount is a tag in use on a user's chats.
+type TagCount struct {
+	Tag   string `bson:"_id" json:"tag"`
+	Count int64  `bson:"count" json:"count"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// Kinds of SearchHit.
+const (
+	HitMessage = "message"
+	HitChat    = "chat" // chat name matched
+	HitMemory  = "memory"
+)
+
+type SearchHit struct {
+	Kind      string  `json:"kind"`
+	ChatId    string  `json:"chatId"`
+	OwnerId   string  `json:"ownerId"`
+	ChatName  string  `json:"chatName"`
+	MsgId     string  `json:"msgId,omitempty"`
+	MemId     string  `json:"memId,omitempty"`
+	Role      string  `json:"role,omitempty"`
+	Snippet   string  `json:"snippet"` // HTML-escaped, matches wrapped in <mark>
+	Score     float64 `json:"score"`
+	Unflushed bool    `json:"unflushed,omitempty"` // still only in Redis
+}
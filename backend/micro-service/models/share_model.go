@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ShareLink is a public, read-only link to a snapshot of a chat taken when the
+// link was created. Only the token's SHA-256 is stored.
+type ShareLink struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserId          string             `bson:"userId" json:"userId"` // chat owner who created the link
+	ChatId          string             `bson:"chatId" json:"chatId"`
+	Prefix          string             `bson:"prefix" json:"prefix"` // first characters of the token, for display only
+	TokenHash       string             `bson:"tokenHash" json:"-"`
+	RedactMemories  bool               `bson:"redactMemories" json:"redactMemories"`
+	RedactFileNames bool               `bson:"redactFileNames" json:"redactFileNames"`
+	Snapshot        ChatExport         `bson:"snapshot" json:"-"`
+	ViewCount       int64              `bson:"viewCount" json:"viewCount"`
+	CreatedAt       time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt       *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	LastViewedAt    *time.Time         `bson:"lastViewedAt,omitempty" json:"lastViewedAt,omitempty"`
+	RevokedAt       *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+type ShareLinkInput struct {
+	ExpiresAt       *time.Time `json:"expiresAt"` // RFC3339; omitted means the link never expires
+	RedactMemories  bool       `json:"redactMemories"`
+	RedactFileNames bool       `json:"redactFileNames"`
+}
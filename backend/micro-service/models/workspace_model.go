@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+type WorkspaceMember struct {
+	UserId  string    `bson:"userId" json:"userId"`
+	Role    string    `bson:"role" json:"role"` // "owner", "editor" or "viewer"
+	AddedAt time.Time `bson:"addedAt" json:"addedAt"`
+}
+
+type Workspace struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	OwnerId   string             `bson:"ownerId" json:"ownerId"`
+	Members   []WorkspaceMember  `bson:"members" json:"members"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
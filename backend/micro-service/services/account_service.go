@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StartDeletionJob returns the user's unfinished deletion job, or creates one.
+// Either way the job is (re)marked as running and its attempt count bumped.
+func StartDeletionJob(userId string) (*models.DeletionJob, error) {
+	jobCollection := config.GetCollection(os.Getenv("DELETION_JOB_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	filter := bson.M{"userId": userId, "status": bson.M{"$ne": "completed"}}
+	update := bson.M{
+		"$set": bson.M{"status": "running", "updatedAt": now},
+		"$inc": bson.M{"attempts": 1},
+		"$setOnInsert": bson.M{
+			"userId":         userId,
+			"completedSteps": []string{},
+			"createdAt":      now,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var job models.DeletionJob
+	if err := jobCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func GetDeletionJob(userId string) (*models.DeletionJob, error) {
+	jobCollection := config.GetCollection(os.Getenv("DELETION_JOB_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	var job models.DeletionJob
+	if err := jobCollection.FindOne(ctx, bson.M{"userId": userId}, opts).Decode(&job); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("no deletion job for this user")
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// FindInterruptedDeletionJobs lists jobs that were still running when the service stopped.
+func FindInterruptedDeletionJobs() ([]models.DeletionJob, error) {
+	return FindMany[models.DeletionJob](
+		os.Getenv("DELETION_JOB_COLLECTION"),
+		bson.M{"status": "running"},
+	)
+}
+
+func updateDeletionJob(jobId primitive.ObjectID, update bson.M) error {
+	jobCollection := config.GetCollection(os.Getenv("DELETION_JOB_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := jobCollection.UpdateByID(ctx, jobId, update)
+	return err
+}
+
+func MarkDeletionStepDone(jobId primitive.ObjectID, step string) error {
+	return updateDeletionJob(jobId, bson.M{
+		"$addToSet": bson.M{"completedSteps": step},
+		"$set":      bson.M{"updatedAt": time.Now().UTC()},
+	})
+}
+
+func MarkDeletionJobFailed(jobId primitive.ObjectID, step string, cause error) error {
+	return updateDeletionJob(jobId, bson.M{
+		"$set": bson.M{
+			"status":    "failed",
+			"lastError": fmt.Sprintf("%s: %v", step, cause),
+			"updatedAt": time.Now().UTC(),
+		},
+	})
+}
+
+func MarkDeletionJobCompleted(jobId primitive.ObjectID) error {
+	return updateDeletionJob(jobId, bson.M{
+		"$set":   bson.M{"status": "completed", "updatedAt": time.Now().UTC()},
+		"$unset": bson.M{"lastError": ""},
+	})
+}
+
+// CountUserUploads counts Upload rows still present for the user.
+func CountUserUploads(userId string) (int64, error) {
+	fileCollection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return fileCollection.CountDocuments(ctx, bson.M{"userId": userId})
+}
+
+// RemoveUserUploadDir removes UPLOAD_PATH/<userId> and anything left in it, except
+// chat directories whose files other users' forks still share; as with
+// RemoveChatUploadDir, those are left for the AI service to remove with their last reference.
+func RemoveUserUploadDir(userId string) error {
+	basePath := os.Getenv("UPLOAD_PATH")
+	if basePath == "" || userId == "" || filepath.Base(userId) != userId {
+		return fmt.Errorf("refusing to remove upload dir for userId=%q", userId)
+	}
+	userDir := filepath.Join(basePath, userId)
+
+	fileCollection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sharedChats, err := fileCollection.Distinct(ctx, "sourceChatId", bson.M{"sourceUserId": userId})
+	if err != nil {
+		return err
+	}
+	if len(sharedChats) == 0 {
+		return os.RemoveAll(userDir)
+	}
+
+	shared := make(map[string]bool, len(sharedChats))
+	for _, chatId := range sharedChats {
+		if id, ok := chatId.(string); ok {
+			shared[id] = true
+		}
+	}
+	entries, err := os.ReadDir(userDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if shared[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(userDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteUserRedisChats removes every chats:<userId>:* hash, including unflushed messages.
+func DeleteUserRedisChats(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	iter := config.RedisClient.Scan(ctx, 0, fmt.Sprintf("chats:%s:*", userId), 100).Iterator()
+	for iter.Next(ctx) {
+		if err := config.RedisClient.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func DeleteUserChats(userId string) error {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := chatCollection.DeleteMany(ctx, bson.M{"userId": userId}); err != nil {
+		return err
+	}
+
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+	_, err := messageCollection.DeleteMany(ctx, bson.M{"userId": userId})
+	return err
+}
+
+func DeleteUserAPIKeys(userId string) error {
+	keyCollection := config.GetCollection(os.Getenv("API_KEY_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := keyCollection.DeleteMany(ctx, bson.M{"userId": userId})
+	return err
+}
+
+func DeleteUserRecord(userId string) error {
+	userCollection := config.GetCollection(os.Getenv("AUTH_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = userCollection.DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
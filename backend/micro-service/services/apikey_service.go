@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKeyPrefix marks a bearer credential as an API key rather than an access token.
+const APIKeyPrefix = "mlk_"
+
+const (
+	ScopeChatsRead     = "chats:read"
+	ScopeChatsWrite    = "chats:write"
+	ScopeMemoriesRead  = "memories:read"
+	ScopeMemoriesWrite = "memories:write"
+	ScopeFilesRead     = "files:read"
+	ScopeFilesWrite    = "files:write"
+	ScopeQuery         = "query"
+)
+
+var validScopes = []string{
+	ScopeChatsRead, ScopeChatsWrite,
+	ScopeMemoriesRead, ScopeMemoriesWrite,
+	ScopeFilesRead, ScopeFilesWrite,
+	ScopeQuery,
+}
+
+func EnsureAPIKeyIndexes() error {
+	keyCollection := config.GetCollection(os.Getenv("API_KEY_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := keyCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "keyHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+	})
+	return err
+}
+
+func CreateAPIKey(userId, name string, scopes []string) (string, *models.APIKey, error) {
+	if len(scopes) == 0 {
+		return "", nil, errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(validScopes, scope) {
+			return "", nil, fmt.Errorf("unknown scope %q (valid: %s)", scope, strings.Join(validScopes, ", "))
+		}
+	}
+
+	keyCollection := config.GetCollection(os.Getenv("API_KEY_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	rawKey := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	key := models.APIKey{
+		ID:        primitive.NewObjectID(),
+		UserId:    userId,
+		Name:      name,
+		Prefix:    rawKey[:len(APIKeyPrefix)+6],
+		KeyHash:   hashToken(rawKey),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if _, err := keyCollection.InsertOne(ctx, key); err != nil {
+		return "", nil, err
+	}
+
+	// The raw key is only ever returned here; Mongo keeps its SHA-256.
+	return rawKey, &key, nil
+}
+
+func ListAPIKeys(userId string) ([]models.APIKey, error) {
+	keyCollection := config.GetCollection(os.Getenv("API_KEY_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := keyCollection.Find(ctx, bson.M{"userId": userId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func RevokeAPIKey(userId, keyId string) error {
+	keyCollection := config.GetCollection(os.Getenv("API_KEY_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(keyId)
+	if err != nil {
+		return err
+	}
+
+	res, err := keyCollection.UpdateOne(ctx,
+		bson.M{"_id": objID, "userId": userId, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now().UTC()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("api key not found or already revoked")
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey resolves a raw key to its (non revoked) record. Usage is
+// recorded separately, once the request has been authorized.
+func AuthenticateAPIKey(rawKey string) (*models.APIKey, error) {
+	keyCollection := config.GetCollection(os.Getenv("API_KEY_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"keyHash":   hashToken(rawKey),
+		"revokedAt": bson.M{"$exists": false},
+	}
+
+	var key models.APIKey
+	if err := keyCollection.FindOne(ctx, filter).Decode(&key); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("invalid or revoked api key")
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// RecordAPIKeyUse bumps a key's request count and last use time.
+func RecordAPIKeyUse(keyId string) error {
+	keyCollection := config.GetCollection(os.Getenv("API_KEY_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(keyId)
+	if err != nil {
+		return err
+	}
+
+	_, err = keyCollection.UpdateOne(ctx,
+		bson.M{"_id": objID},
+		bson.M{
+			"$set": bson.M{"lastUsedAt": time.Now().UTC()},
+			"$inc": bson.M{"requestCount": 1},
+		},
+	)
+	return err
+}
@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
@@ -11,8 +15,49 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// passwordHashCost reads the bcrypt cost from BCRYPT_COST, falling back to bcrypt.DefaultCost.
+func passwordHashCost() int {
+	cost, err := strconv.Atoi(os.Getenv("BCRYPT_COST"))
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Rows created before hashing was introduced hold the raw password.
+func isLegacyPlaintext(stored string) bool {
+	return !strings.HasPrefix(stored, "$2a$") &&
+		!strings.HasPrefix(stored, "$2b$") &&
+		!strings.HasPrefix(stored, "$2y$")
+}
+
+// checkPassword reports whether password matches the stored value and whether
+// the stored value should be re-hashed (legacy plaintext or outdated cost).
+func checkPassword(stored, password string) (ok bool, needsRehash bool) {
+	if isLegacyPlaintext(stored) {
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+		return false, false
+	}
+
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err != nil || cost != passwordHashCost()
+}
+
 func RegisterUser(email, password string) (string, string, error) {
 	userCollection := config.GetCollection(os.Getenv("AUTH_COLLECTION"))
 
@@ -32,11 +77,16 @@ func RegisterUser(email, password string) (string, string, error) {
 		return "", "", err
 	}
 
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return "", "", err
+	}
+
 	// Create new user
 	user := models.User{
 		ID:       primitive.NewObjectID(), // Add ID manually
 		Email:    email,
-		Password: password,
+		Password: hashed,
 	}
 
 	_, err = userCollection.InsertOne(ctx, user)
@@ -44,7 +94,12 @@ func RegisterUser(email, password string) (string, string, error) {
 		return "", "", err
 	}
 
-	return user.ID.Hex(), "User Registered!", nil
+	if err := SendVerificationEmail(user.ID.Hex(), user.Email); err != nil {
+		log.Printf("[RegisterUser (Auth Service)] ERROR sending verification email for userId=%s: %v", user.ID.Hex(), err)
+		return user.ID.Hex(), "User Registered! Verification email could not be sent.", nil
+	}
+
+	return user.ID.Hex(), "User Registered! Check your inbox to verify your email.", nil
 }
 
 func ValidateUser(email, password string) (*models.User, error) {
@@ -63,9 +118,29 @@ func ValidateUser(email, password string) (*models.User, error) {
 		return nil, errors.New("user not in DB")
 	}
 
-	if user.Password != password {
+	ok, needsRehash := checkPassword(user.Password, password)
+	if !ok {
 		return nil, errors.New("incorrect password")
 	}
 
+	// Transparently upgrade legacy plaintext rows and hashes made with an old cost.
+	if needsRehash {
+		hashed, err := hashPassword(password)
+		if err != nil {
+			log.Printf("[ValidateUser (Auth Service)] ERROR re-hashing password for userId=%s: %v", user.ID.Hex(), err)
+			return &user, nil
+		}
+
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "password": user.Password},
+			bson.M{"$set": bson.M{"password": hashed}},
+		)
+		if err != nil {
+			log.Printf("[ValidateUser (Auth Service)] ERROR storing re-hashed password for userId=%s: %v", user.ID.Hex(), err)
+		} else {
+			user.Password = hashed
+		}
+	}
+
 	return &user, nil
 }
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
@@ -34,7 +36,6 @@ func Debug(userId string) ([]models.Chat, error) {
 		"userId":    1,
 		"chatId":    1,
 		"name":      1,
-		"messages":  1,
 		"memory":    1,
 		"createdAt": 1,
 	}
@@ -55,6 +56,9 @@ func Debug(userId string) ([]models.Chat, error) {
 		if err := cursor.Decode(&chat); err != nil {
 			return nil, err
 		}
+		if chat.Messages, err = LoadChatMessages(ctx, chat.UserId, chat.ChatId); err != nil {
+			return nil, err
+		}
 		chats = append(chats, chat)
 	}
 
@@ -66,25 +70,40 @@ func Debug(userId string) ([]models.Chat, error) {
 
 }
 
-func CreateChat(userId, chatName string) (string, error) {
+func CreateChat(userId, chatName, workspaceId string) (string, error) {
 	chatCollection := config.GetCollection(
 		os.Getenv("CHAT_COLLECTION"),
 	)
 
+	// Creating a chat inside a workspace needs at least editor rights there
+	if workspaceId != "" {
+		if _, err := requireWorkspaceRole(workspaceId, userId, models.RoleEditor); err != nil {
+			return "", err
+		}
+	}
+
+	// Unnamed chats get the default name, which the AI service later replaces with a title
+	chatName = strings.TrimSpace(chatName)
+	if chatName == "" {
+		chatName = models.DefaultChatName
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	// Check if userId, chatId chat exists
 
 	chatId := primitive.NewObjectID().Hex()
+	now := time.Now().UTC()
 
 	chat := models.Chat{
-		UserId:    userId,
-		ChatId:    chatId,
-		Name:      chatName,
-		Messages:  []models.Message{},
-		Memory:    []models.Memory{},
-		CreatedAt: time.Now().UTC(),
+		UserId:      userId,
+		ChatId:      chatId,
+		WorkspaceId: workspaceId,
+		Name:        chatName,
+		Memory:      []models.Memory{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
 	_, err := chatCollection.InsertOne(ctx, chat)
@@ -95,6 +114,8 @@ func CreateChat(userId, chatName string) (string, error) {
 	return chatId, nil
 }
 
+// DeleteChat moves a chat to the trash. It stays restorable until the purge job
+// removes it for good once TrashRetention has passed.
 func DeleteChat(userId, chatId string) error {
 	chatCollection := config.GetCollection(
 		os.Getenv("CHAT_COLLECTION"),
@@ -102,26 +123,96 @@ func DeleteChat(userId, chatId string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var existing models.Chat
-	// Check if chat exists
-	err := chatCollection.FindOne(ctx, bson.M{"userId": userId, "chatId": chatId}).Decode(&existing)
+	result, err := chatCollection.UpdateOne(ctx,
+		bson.M{"userId": userId, "chatId": chatId, "deletedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"deletedAt": time.Now().UTC()}},
+	)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return fmt.Errorf("chat with userId=%s and chatId=%s not found", userId, chatId)
+		return fmt.Errorf("failed to delete chat: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("chat with userId=%s and chatId=%s not found", userId, chatId)
+	}
+	return nil
+
+}
+
+func ChatExists(userId, chatId string) (bool, error) {
+	chatCollection := config.GetCollection(
+		os.Getenv("CHAT_COLLECTION"),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := chatCollection.CountDocuments(ctx, bson.M{"userId": userId, "chatId": chatId}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Values of ChatHeadsFilter.Archived.
+const (
+	ArchivedExclude = "false" // default: hide archived chats
+	ArchivedOnly    = "true"
+	ArchivedAll     = "all"
+)
+
+var ErrInvalidArchivedFilter = fmt.Errorf("archived must be one of %s, %s, %s", ArchivedExclude, ArchivedOnly, ArchivedAll)
+
+const (
+	DefaultChatHeadsPageSize = 30
+	MaxChatHeadsPageSize     = 100
+)
+
+type ChatHeadsFilter struct {
+	Archived string
+	FolderId string // a folder's id, FolderNone for unfiled chats, or "" for any
+	Tag      string
+	Limit    int
+	Offset   int
+}
+
+func UpdateChat(userId, chatId string, update models.ChatUpdate) error {
+	chatCollection := config.GetCollection(
+		os.Getenv("CHAT_COLLECTION"),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{}
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return errors.New("name cannot be empty")
 		}
-		return fmt.Errorf("failed to check chat existence: %w", err)
+		set["name"] = name
+	}
+	if update.Pinned != nil {
+		set["pinned"] = *update.Pinned
+	}
+	if update.Archived != nil {
+		set["archived"] = *update.Archived
+	}
+	if len(set) == 0 {
+		return errors.New("nothing to update")
 	}
 
-	// Delete the chat
-	_, err = chatCollection.DeleteOne(ctx, bson.M{"userId": userId, "chatId": chatId})
+	result, err := chatCollection.UpdateOne(ctx, bson.M{"userId": userId, "chatId": chatId}, bson.M{"$set": set})
 	if err != nil {
-		return fmt.Errorf("failed to delete chat: %w", err)
+		return fmt.Errorf("failed to update chat: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user or chat not found")
 	}
-	return nil
 
+	return nil
 }
 
-func GetChatHeads(userId string) ([]models.ChatHeads, error) {
+func GetChatHeads(userId string, headsFilter ChatHeadsFilter) ([]models.ChatHeads, bool, error) {
 	chatCollection := config.GetCollection(
 		os.Getenv("CHAT_COLLECTION"),
 	)
@@ -129,102 +220,374 @@ func GetChatHeads(userId string) ([]models.ChatHeads, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Own chats plus chats shared through the user's workspaces
+	workspaceIds, err := MemberWorkspaceIds(userId)
+	if err != nil {
+		return nil, false, err
+	}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"userId": userId},
+		bson.M{"workspaceId": bson.M{"$in": workspaceIds}},
+	}}
+
 	// Check if userId exist
-	exists := chatCollection.FindOne(ctx, bson.M{"userId": userId})
+	exists := chatCollection.FindOne(ctx, filter)
 	if exists.Err() != nil {
-		return nil, errors.New("user not found")
+		return nil, false, errors.New("user not found")
+	}
+
+	switch headsFilter.Archived {
+	case "", ArchivedExclude:
+		filter["archived"] = bson.M{"$ne": true}
+	case ArchivedOnly:
+		filter["archived"] = true
+	case ArchivedAll:
+	default:
+		return nil, false, ErrInvalidArchivedFilter
+	}
+	filter["deletedAt"] = bson.M{"$exists": false} // trashed chats are listed by GetTrashedChats
+
+	switch headsFilter.FolderId {
+	case "":
+	case FolderNone:
+		filter["folderId"] = bson.M{"$exists": false}
+	default:
+		filter["folderId"] = headsFilter.FolderId
+	}
+	if headsFilter.Tag != "" {
+		tag, err := CleanTag(headsFilter.Tag)
+		if err != nil {
+			return nil, false, err
+		}
+		filter["tags"] = tag
 	}
 
-	// Projection
-	projection := bson.M{
-		"chatId":   1,
-		"name":     1,
-		"messages": 1,
+	if headsFilter.Limit <= 0 {
+		headsFilter.Limit = DefaultChatHeadsPageSize
 	}
+	headsFilter.Limit = min(headsFilter.Limit, MaxChatHeadsPageSize)
 
-	opts := options.Find().SetProjection(projection)
+	// Projection: heads only need the denormalized activity fields, never message bodies
+	projection := bson.M{
+		"userId":       1,
+		"chatId":       1,
+		"workspaceId":  1,
+		"name":         1,
+		"pinned":       1,
+		"archived":     1,
+		"folderId":     1,
+		"tags":         1,
+		"lastMessage":  1,
+		"messageCount": 1,
+		"updatedAt":    1,
+		"createdAt":    1,
+	}
+
+	// Pinned chats first, then most recently active; one extra row tells us if more remain
+	opts := options.Find().
+		SetProjection(projection).
+		SetSort(bson.D{{Key: "pinned", Value: -1}, {Key: "updatedAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(headsFilter.Offset)).
+		SetLimit(int64(headsFilter.Limit + 1))
 
 	// Find all the Chats for this user with the valid projection
-	cursor, err := chatCollection.Find(ctx, bson.M{"userId": userId}, opts)
+	cursor, err := chatCollection.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer cursor.Close(ctx)
 
-	var heads []models.ChatHeads
+	heads := []models.ChatHeads{}
 
 	// Iterate over the Chats and populate the for the relevant chatHeads array
 	for cursor.Next(ctx) {
 		var chat models.Chat
 		if err := cursor.Decode(&chat); err != nil { // Perfectly safe to decode partial data into a struct.
-			return nil, err
+			return nil, false, err
 		}
 
 		preview := "No messages yet"
 
-		if len(chat.Messages) > 0 {
-			preview = chat.Messages[0].Content
+		if chat.LastMessage != "" {
+			preview = chat.LastMessage
+		}
+
+		tags := chat.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+
+		// Chats that never received a message are as fresh as their creation
+		updatedAt := chat.UpdatedAt
+		if updatedAt.IsZero() {
+			updatedAt = chat.CreatedAt
 		}
 
 		head := models.ChatHeads{
-			ChatId:  chat.ChatId,
-			Name:    chat.Name,
-			Preview: preview,
+			ChatId:       chat.ChatId,
+			OwnerId:      chat.UserId,
+			WorkspaceId:  chat.WorkspaceId,
+			Name:         chat.Name,
+			Pinned:       chat.Pinned,
+			Archived:     chat.Archived,
+			FolderId:     chat.FolderId,
+			Tags:         tags,
+			Preview:      preview,
+			MessageCount: chat.MessageCount,
+			UpdatedAt:    updatedAt,
 		}
 
 		heads = append(heads, head)
 	}
 
 	if err := cursor.Err(); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	return heads, nil
+	hasMore := len(heads) > headsFilter.Limit
+	if hasMore {
+		heads = heads[:headsFilter.Limit]
+	}
+
+	return heads, hasMore, nil
 
 }
 
-func GetChatMessages(userId, chatId string) (*models.ChatMessages, error) {
-	chatCollection := config.GetCollection(
-		os.Getenv("CHAT_COLLECTION"),
+const (
+	DefaultMessagePageSize = 50
+	MaxMessagePageSize     = 200
+)
+
+var ErrInvalidCursor = errors.New("cursor must be a msgId or an RFC3339 timestamp")
+
+// MessagePage selects a window of a chat's history. Before and After are
+// mutually exclusive cursors (msgId or RFC3339 timestamp); with neither set
+// the newest Limit messages are returned.
+type MessagePage struct {
+	Before string
+	After  string
+	Limit  int
+}
+
+// cursorFilter turns a cursor into a filter on the messages collection selecting
+// everything strictly older (or, with after, strictly newer) than it. For a
+// timestamp cursor it also returns the time, to window unflushed messages with.
+func cursorFilter(ctx context.Context, userId, chatId, cursor string, after bool) (bson.M, *time.Time, error) {
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+
+	op := "$lt"
+	if after {
+		op = "$gt"
+	}
+
+	var anchor models.ChatMessage
+	err := messageCollection.FindOne(ctx,
+		bson.M{"userId": userId, "chatId": chatId, "msgId": cursor},
+		options.FindOne().SetProjection(bson.M{"seq": 1}),
+	).Decode(&anchor)
+	if err == nil {
+		return bson.M{"seq": bson.M{op: anchor.Seq}}, nil, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, err
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, cursor)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	at = at.UTC()
+	return bson.M{"createdAt": bson.M{op: at}}, &at, nil
+}
+
+// unflushedMessages splits a chat's Redis messages into those not yet in Mongo, which
+// come after everything stored, and the Redis copies of ones a flush already stored.
+func unflushedMessages(ctx context.Context, userId, chatId string) ([]models.Message, map[string]models.Message, error) {
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+
+	pending, err := UnflushedMessages(ctx, userId, chatId)
+	if err != nil || len(pending) == 0 {
+		return nil, nil, err
+	}
+
+	ids := make([]string, len(pending))
+	for i, m := range pending {
+		ids[i] = m.MsgID
+	}
+	found, err := messageCollection.Find(ctx,
+		bson.M{"userId": userId, "chatId": chatId, "msgId": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"msgId": 1}),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stored []models.ChatMessage
+	if err := found.All(ctx, &stored); err != nil {
+		return nil, nil, err
+	}
+	if len(stored) == 0 {
+		return pending, nil, nil
+	}
+
+	isStored := make(map[string]bool, len(stored))
+	for _, d := range stored {
+		isStored[d.MsgID] = true
+	}
+	tail := make([]models.Message, 0, len(pending)-len(stored))
+	copies := make(map[string]models.Message, len(stored))
+	for _, m := range pending {
+		if isStored[m.MsgID] {
+			copies[m.MsgID] = m
+		} else {
+			tail = append(tail, m)
+		}
+	}
+	return tail, copies, nil
+}
+
+// unflushedBeyond keeps the unflushed messages on the requested side of a cursor
+// that is a stored message (at is nil) or a timestamp. Unflushed messages are newer
+// than every stored one; one without a readable timestamp counts as the newest.
+func unflushedBeyond(tail []models.Message, at *time.Time, after bool) []models.Message {
+	if at == nil {
+		if after {
+			return tail
+		}
+		return nil
+	}
+	return slices.DeleteFunc(slices.Clone(tail), func(m models.Message) bool {
+		ts, err := time.Parse(time.RFC3339Nano, m.Timestamp)
+		if err != nil {
+			return !after
+		}
+		if after {
+			return !ts.After(*at)
+		}
+		return !ts.Before(*at)
+	})
+}
+
+// GetChatMessages returns a page of a chat's history. Messages still waiting in Redis
+// for a flush are merged in after the stored ones, so the page matches what was streamed.
+func GetChatMessages(userId, chatId string, page MessagePage) (*models.ChatMessages, error) {
+	messageCollection := config.GetCollection(
+		os.Getenv("MESSAGE_COLLECTION"),
 	)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Projection: only pull the messages field
-	projection := bson.M{
-		"messages": 1,
-		"_id":      0, // optional: avoids returning the document _id
+	if page.Before != "" && page.After != "" {
+		return nil, errors.New("only one of before/after may be set")
 	}
+	if page.Limit <= 0 {
+		page.Limit = DefaultMessagePageSize
+	}
+	page.Limit = min(page.Limit, MaxMessagePageSize)
 
-	opts := options.FindOne().SetProjection(projection)
-
-	// Fetch the relevant chat for userId and chatId
-	var chat models.Chat
-	err := chatCollection.FindOne(ctx, bson.M{"userId": userId, "chatId": chatId}, opts).Decode(&chat)
+	exists, err := ChatExists(userId, chatId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("user or chat not found")
+	}
 
+	tail, copies, err := unflushedMessages(ctx, userId, chatId)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, errors.New("user or chat not found")
-		}
 		return nil, err
 	}
 
-	// Normalize messages
-	msgMap := make(map[string]models.Message, len(chat.Messages))
-	order := make([]string, 0, len(chat.Messages))
+	filter := bson.M{"userId": userId, "chatId": chatId}
+	cursor, after := page.Before, false
+	if page.After != "" {
+		cursor, after = page.After, true
+	}
+	skipStored := false
+	if cursor != "" {
+		if i := slices.IndexFunc(tail, func(m models.Message) bool { return m.MsgID == cursor }); i >= 0 {
+			// An unflushed cursor: every stored message is older than it
+			if after {
+				tail, skipStored = tail[i+1:], true
+			} else {
+				tail = tail[:i]
+			}
+		} else {
+			bound, at, err := cursorFilter(ctx, userId, chatId, cursor, after)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range bound {
+				filter[k] = v
+			}
+			tail = unflushedBeyond(tail, at, after)
+		}
+	}
+
+	// Walk away from the cursor and fetch one extra row to learn whether more remain
+	var docs []models.ChatMessage
+	if !skipStored {
+		direction := -1
+		if after {
+			direction = 1
+		}
+		opts := options.Find().
+			SetSort(bson.D{{Key: "seq", Value: direction}}).
+			SetLimit(int64(page.Limit + 1))
 
-	for _, m := range chat.Messages {
-		msgMap[m.MsgID] = m
-		order = append(order, m.MsgID)
+		found, err := messageCollection.Find(ctx, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err := found.All(ctx, &docs); err != nil {
+			return nil, err
+		}
+	}
+	if !after {
+		slices.Reverse(docs)
+	}
+
+	// A stored message still in Redis takes its Redis copy, whose variant flags may be newer
+	stored := make([]models.Message, len(docs))
+	for i, d := range docs {
+		stored[i] = d.Message
+		if m, ok := copies[d.MsgID]; ok {
+			stored[i] = m
+		}
 	}
+	messages := MergeMessages(stored, tail)
 
-	return &models.ChatMessages{
+	result := &models.ChatMessages{
 		UserId:   userId,
 		ChatId:   chatId,
-		Messages: msgMap,
-		Order:    order,
-	}, nil
+		Messages: make(map[string]models.Message, len(messages)),
+		Order:    make([]string, 0, len(messages)),
+	}
+	if len(messages) > page.Limit {
+		result.HasMore = true
+		if after {
+			messages = messages[:page.Limit]
+		} else {
+			messages = messages[len(messages)-page.Limit:]
+		}
+	}
+
+	// Normalize messages
+	for _, m := range messages {
+		result.Messages[m.MsgID] = m
+		result.Order = append(result.Order, m.MsgID)
+	}
+
+	// Older pages continue from the oldest message returned, newer ones from the newest
+	if result.HasMore && len(result.Order) > 0 {
+		if after {
+			result.NextCursor = result.Order[len(result.Order)-1]
+		} else {
+			result.NextCursor = result.Order[0]
+		}
+	}
+
+	return result, nil
 }
 
 func AddMemory(userId, chatId, memoryContext string) (string, error) {
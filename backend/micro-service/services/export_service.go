@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Supported export formats.
+const (
+	ExportMarkdown = "md"
+	ExportJSON     = "json"
+	ExportHTML     = "html"
+)
+
+var ErrUnknownExportFormat = errors.New("format must be one of md, json, html")
+
+// BuildChatExport gathers the active branch of a chat's messages (stored and still
+// in Redis), its memories and attached files.
+func BuildChatExport(userId, chatId string) (*models.ChatExport, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+	fileCollection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var chat models.Chat
+	err := chatCollection.FindOne(ctx,
+		bson.M{"userId": userId, "chatId": chatId},
+		options.FindOne().SetProjection(bson.M{"chatId": 1, "name": 1, "memory": 1, "createdAt": 1}),
+	).Decode(&chat)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("user or chat not found")
+		}
+		return nil, err
+	}
+
+	stored, err := LoadChatMessages(ctx, userId, chatId)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := UnflushedMessages(ctx, userId, chatId)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := fileCollection.Find(ctx,
+		bson.M{"userId": userId, "chatId": chatId},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var uploads []models.Upload
+	if err := cursor.All(ctx, &uploads); err != nil {
+		return nil, err
+	}
+
+	files := make([]models.ExportedFile, 0, len(uploads))
+	for _, u := range uploads {
+		files = append(files, models.ExportedFile{
+			FileName:  u.FileName,
+			FileType:  u.FileType,
+			CreatedAt: u.CreatedAt,
+			Status:    u.Status,
+		})
+	}
+
+	memories := chat.Memory
+	if memories == nil {
+		memories = []models.Memory{}
+	}
+
+	return &models.ChatExport{
+		ChatId:     chat.ChatId,
+		Name:       chat.Name,
+		CreatedAt:  chat.CreatedAt,
+		ExportedAt: time.Now().UTC(),
+		Messages:   ActiveBranch(MergeMessages(stored, pending)),
+		Memories:   memories,
+		Files:      files,
+	}, nil
+}
+
+// RenderChatExport serializes an export and returns it with its content type.
+func RenderChatExport(export *models.ChatExport, format string) ([]byte, string, error) {
+	switch format {
+	case ExportMarkdown:
+		return []byte(renderMarkdown(export)), "text/markdown; charset=utf-8", nil
+	case ExportJSON:
+		data, err := json.MarshalIndent(export, "", "  ")
+		return data, "application/json; charset=utf-8", err
+	case ExportHTML:
+		var buf bytes.Buffer
+		if err := exportTemplate.Execute(&buf, export); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "text/html; charset=utf-8", nil
+	default:
+		return nil, "", ErrUnknownExportFormat
+	}
+}
+
+func roleLabel(role string) string {
+	switch role {
+	case "user":
+		return "User"
+	case "", "ai", "model", "assistant":
+		return "Assistant"
+	default:
+		return strings.ToUpper(role[:1]) + role[1:]
+	}
+}
+
+func renderMarkdown(export *models.ChatExport) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", export.Name)
+	fmt.Fprintf(&b, "_Created %s · exported %s_\n\n", export.CreatedAt.Format(time.RFC3339), export.ExportedAt.Format(time.RFC3339))
+
+	if len(export.Memories) > 0 {
+		b.WriteString("## Memories\n\n")
+		for _, m := range export.Memories {
+			fmt.Fprintf(&b, "- %s\n", strings.Join(strings.Fields(m.Context), " "))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(export.Files) > 0 {
+		b.WriteString("## Files\n\n")
+		for _, f := range export.Files {
+			fmt.Fprintf(&b, "- %s (%s)\n", f.FileName, f.FileType)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Conversation\n\n")
+	for _, m := range export.Messages {
+		fmt.Fprintf(&b, "### %s · %s\n\n%s\n\n", roleLabel(m.Role), m.Timestamp, m.Content)
+	}
+
+	return b.String()
+}
+
+// exportTemplate renders a single self-contained page: inline styles, no external assets.
+var exportTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"role": roleLabel,
+	"time": func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 760px; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+h1 { margin-bottom: .25rem; }
+.meta { color: #656d76; font-size: .85rem; }
+.msg { border-radius: 8px; padding: .75rem 1rem; margin: .75rem 0; white-space: pre-wrap; }
+.msg.user { background: #ddf4ff; }
+.msg.assistant { background: #f6f8fa; }
+.msg header { font-weight: 600; font-size: .8rem; margin-bottom: .35rem; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p class="meta">Created {{time .CreatedAt}} · exported {{time .ExportedAt}}</p>
+{{if .Memories}}<h2>Memories</h2>
+<ul>{{range .Memories}}<li>{{.Context}}</li>{{end}}</ul>
+{{end}}{{if .Files}}<h2>Files</h2>
+<ul>{{range .Files}}<li>{{.FileName}} ({{.FileType}})</li>{{end}}</ul>
+{{end}}<h2>Conversation</h2>
+{{range .Messages}}<section class="msg {{if eq .Role "user"}}user{{else}}assistant{{end}}">
+<header>{{role .Role}} · <span class="meta">{{.Timestamp}}</span></header>
+{{.Content}}</section>
+{{end}}</body>
+</html>
+`))
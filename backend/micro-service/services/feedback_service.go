@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	MaxFeedbackReasonLength = 2000
+	MaxFeedbackTags         = 10
+	MaxFeedbackTagLength    = 40
+)
+
+var (
+	ErrFeedbackMessageNotFound = errors.New("message not found in this chat")
+	ErrFeedbackNotAIMessage    = errors.New("only AI messages can be rated")
+	ErrInvalidFeedback         = errors.New("invalid feedback")
+	ErrInvalidRating           = fmt.Errorf("%w: rating must be %q or %q", ErrInvalidFeedback, models.RatingUp, models.RatingDown)
+)
+
+// FeedbackExportFilter narrows an ExportFeedback run; zero values match everything.
+type FeedbackExportFilter struct {
+	Since  time.Time
+	Until  time.Time
+	Rating string
+}
+
+// EnsureFeedbackIndexes creates the indexes the feedback paths rely on. Safe to call on every start.
+func EnsureFeedbackIndexes() error {
+	feedbackCollection := config.GetCollection(os.Getenv("FEEDBACK_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := feedbackCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// One rating per user per message; rating again replaces it
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "ownerId", Value: 1}, {Key: "chatId", Value: 1}, {Key: "msgId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "updatedAt", Value: 1}},
+		},
+	})
+	return err
+}
+
+// normalizeTags lowercases, trims and de-duplicates feedback tags, dropping empty ones.
+func normalizeTags(tags []string) ([]string, error) {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slices.Contains(out, tag) {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > MaxFeedbackTagLength {
+			return nil, fmt.Errorf("%w: tags must be at most %d characters", ErrInvalidFeedback, MaxFeedbackTagLength)
+		}
+		out = append(out, tag)
+	}
+	if len(out) > MaxFeedbackTags {
+		return nil, fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidFeedback, MaxFeedbackTags)
+	}
+	return out, nil
+}
+
+// RecordFeedback stores userId's rating of the AI message msgId in ownerId's chatId,
+// replacing any earlier rating of theirs. The reply, the query it answered and its
+// generation details are copied onto the feedback.
+func RecordFeedback(userId, ownerId, chatId, msgId string, input models.FeedbackInput) (*models.Feedback, error) {
+	feedbackCollection := config.GetCollection(os.Getenv("FEEDBACK_COLLECTION"))
+
+	if input.Rating != models.RatingUp && input.Rating != models.RatingDown {
+		return nil, ErrInvalidRating
+	}
+	reason := strings.TrimSpace(input.Reason)
+	if utf8.RuneCountInString(reason) > MaxFeedbackReasonLength {
+		return nil, fmt.Errorf("%w: reason must be at most %d characters", ErrInvalidFeedback, MaxFeedbackReasonLength)
+	}
+	tags, err := normalizeTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reply, parent, err := messageAndParent(ctx, ownerId, chatId, msgId)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrFeedbackMessageNotFound
+	}
+	if reply.Role != "ai" {
+		return nil, ErrFeedbackNotAIMessage
+	}
+	query := ""
+	if parent != nil && parent.Role == "user" {
+		query = parent.Content
+	}
+
+	now := time.Now().UTC()
+	var feedback models.Feedback
+	err = feedbackCollection.FindOneAndUpdate(ctx,
+		bson.M{"userId": userId, "ownerId": ownerId, "chatId": chatId, "msgId": msgId},
+		bson.M{
+			"$set": bson.M{
+				"rating":     input.Rating,
+				"reason":     reason,
+				"tags":       tags,
+				"query":      query,
+				"response":   reply.Content,
+				"generation": reply.Generation,
+				"updatedAt":  now,
+			},
+			"$setOnInsert": bson.M{"createdAt": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&feedback)
+	if err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+// messageAndParent finds one message of a chat and the message it follows, without
+// loading the history. Either is nil if not found. The message may still be waiting
+// in Redis for a flush, and a Redis copy wins over a stored one.
+func messageAndParent(ctx context.Context, ownerId, chatId, msgId string) (*models.Message, *models.Message, error) {
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+
+	pending, err := UnflushedMessages(ctx, ownerId, chatId)
+	if err != nil {
+		return nil, nil, err
+	}
+	// find returns the message, its index in pending (or -1) and its seq if stored
+	find := func(id string) (*models.Message, int, int64, error) {
+		if i := slices.IndexFunc(pending, func(m models.Message) bool { return m.MsgID == id }); i >= 0 {
+			return &pending[i], i, 0, nil
+		}
+		var doc models.ChatMessage
+		err := messageCollection.FindOne(ctx, bson.M{"userId": ownerId, "chatId": chatId, "msgId": id}).Decode(&doc)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, -1, 0, nil
+			}
+			return nil, -1, 0, err
+		}
+		return &doc.Message, -1, doc.Seq, nil
+	}
+	// storedBefore returns the newest stored message older than seq, or the newest of all with seq 0
+	storedBefore := func(seq int64) (*models.Message, error) {
+		f := bson.M{"userId": ownerId, "chatId": chatId}
+		if seq != 0 {
+			f["seq"] = bson.M{"$lt": seq}
+		}
+		var doc models.ChatMessage
+		err := messageCollection.FindOne(ctx, f, options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})).Decode(&doc)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return &doc.Message, nil
+	}
+	msg, index, seq, err := find(msgId)
+	if err != nil || msg == nil {
+		return nil, nil, err
+	}
+
+	var parent *models.Message
+	switch {
+	case msg.ParentId == models.ThreadRoot:
+	case msg.ParentId != "":
+		parent, _, _, err = find(msg.ParentId)
+	// Messages stored before branching existed follow the one before them
+	case index > 0:
+		parent = &pending[index-1]
+	case index == 0:
+		parent, err = storedBefore(0)
+	default:
+		parent, err = storedBefore(seq)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, parent, nil
+}
+
+// ExportFeedback writes the feedback matching filter to w as JSON Lines, oldest
+// first, and returns how many records were written.
+func ExportFeedback(ctx context.Context, w io.Writer, filter FeedbackExportFilter) (int, error) {
+	feedbackCollection := config.GetCollection(os.Getenv("FEEDBACK_COLLECTION"))
+
+	query := bson.M{}
+	updated := bson.M{}
+	if !filter.Since.IsZero() {
+		updated["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		updated["$lt"] = filter.Until
+	}
+	if len(updated) > 0 {
+		query["updatedAt"] = updated
+	}
+	if filter.Rating != "" {
+		if filter.Rating != models.RatingUp && filter.Rating != models.RatingDown {
+			return 0, ErrInvalidRating
+		}
+		query["rating"] = filter.Rating
+	}
+
+	cursor, err := feedbackCollection.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "updatedAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	written := 0
+	for cursor.Next(ctx) {
+		var feedback models.Feedback
+		if err := cursor.Decode(&feedback); err != nil {
+			return written, err
+		}
+		if err := enc.Encode(feedback); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, cursor.Err()
+}
+
+// DeleteUserFeedback removes the feedback a user gave and the feedback left on their chats.
+func DeleteUserFeedback(userId string) error {
+	feedbackCollection := config.GetCollection(os.Getenv("FEEDBACK_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := feedbackCollection.DeleteMany(ctx, bson.M{"$or": bson.A{
+		bson.M{"userId": userId},
+		bson.M{"ownerId": userId},
+	}})
+	return err
+}
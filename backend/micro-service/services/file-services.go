@@ -44,7 +44,7 @@ type FileResponse struct {
 	Error             string    `json:"error"`
 }
 
-func HandleFileUpload(userId, chatId string, fileHeaderArr []*multipart.FileHeader) UploadSummary {
+func HandleFileUpload(userId, chatId, workspaceId string, fileHeaderArr []*multipart.FileHeader) UploadSummary {
 
 	uploadCollection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
 
@@ -123,6 +123,7 @@ func HandleFileUpload(userId, chatId string, fileHeaderArr []*multipart.FileHead
 			doc := models.Upload{
 				UserId:            userId,
 				ChatId:            chatId,
+				WorkspaceId:       workspaceId,
 				FileName:          fh.Filename,
 				FileType:          contentType,
 				CreatedAt:         time.Now(),
@@ -268,6 +269,13 @@ func HandleFilesDelete(uploads []models.Upload) {
 			continue
 		}
 
+		// Files shared with forked chats are removed by the AI service once the last reference goes
+		if shared, err := IsSharedUpload(upload); err != nil || shared {
+			log.Printf("[HandleFilesDelete (File Service)] Kept shared file for fileId=%s userId=%s chatId=%s (err=%v)",
+				upload.ID.Hex(), upload.UserId, upload.ChatId, err)
+			continue
+		}
+
 		// Launches goroutine to take care of deletion of files
 		go func(u models.Upload, f string) {
 			// Check if filepath exist
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const MaxFolderNameLength = 64
+
+// FolderNone, as ChatHeadsFilter.FolderId, selects chats that are in no folder.
+const FolderNone = "none"
+
+var (
+	ErrFolderNotFound    = errors.New("folder not found")
+	ErrFolderExists      = errors.New("a folder with this name already exists")
+	ErrInvalidFolderName = fmt.Errorf("folder name must be 1-%d characters", MaxFolderNameLength)
+)
+
+// EnsureFolderIndexes creates the indexes folders and tag filtering rely on. Safe to call on every start.
+func EnsureFolderIndexes() error {
+	folderCollection := config.GetCollection(os.Getenv("FOLDER_COLLECTION"))
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := folderCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = chatCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "folderId", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "tags", Value: 1}}},
+	})
+	return err
+}
+
+func cleanFolderName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxFolderNameLength {
+		return "", ErrInvalidFolderName
+	}
+	return name, nil
+}
+
+func CreateFolder(userId, name string) (*models.Folder, error) {
+	folderCollection := config.GetCollection(os.Getenv("FOLDER_COLLECTION"))
+
+	name, err := cleanFolderName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	folder := models.Folder{
+		ID:        primitive.NewObjectID(),
+		UserId:    userId,
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
+	}
+	if _, err := folderCollection.InsertOne(ctx, folder); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrFolderExists
+		}
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// ListFolders returns the user's folders by name, each with the number of live chats in it.
+func ListFolders(userId string) ([]models.Folder, error) {
+	folderCollection := config.GetCollection(os.Getenv("FOLDER_COLLECTION"))
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := folderCollection.Find(ctx, bson.M{"userId": userId}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	folders := []models.Folder{}
+	if err := cursor.All(ctx, &folders); err != nil {
+		return nil, err
+	}
+	if len(folders) == 0 {
+		return folders, nil
+	}
+
+	cursor, err = chatCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"userId": userId, "folderId": bson.M{"$exists": true}, "deletedAt": bson.M{"$exists": false}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$folderId", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		FolderId string `bson:"_id"`
+		Count    int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	byFolder := make(map[string]int64, len(counts))
+	for _, c := range counts {
+		byFolder[c.FolderId] = c.Count
+	}
+	for i := range folders {
+		folders[i].ChatCount = byFolder[folders[i].ID.Hex()]
+	}
+	return folders, nil
+}
+
+func RenameFolder(userId, folderId, name string) error {
+	folderCollection := config.GetCollection(os.Getenv("FOLDER_COLLECTION"))
+
+	name, err := cleanFolderName(name)
+	if err != nil {
+		return err
+	}
+	objID, err := primitive.ObjectIDFromHex(folderId)
+	if err != nil {
+		return ErrFolderNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := folderCollection.UpdateOne(ctx, bson.M{"_id": objID, "userId": userId}, bson.M{"$set": bson.M{"name": name}})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrFolderExists
+		}
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrFolderNotFound
+	}
+	return nil
+}
+
+// DeleteFolder removes a folder; the chats in it are kept and become unfiled.
+// Chats are unfiled before the folder goes, so a failure never leaves them pointing
+// at a deleted folder, and once more after it for moves that raced the delete.
+func DeleteFolder(userId, folderId string) error {
+	folderCollection := config.GetCollection(os.Getenv("FOLDER_COLLECTION"))
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	objID, err := primitive.ObjectIDFromHex(folderId)
+	if err != nil {
+		return ErrFolderNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count, err := folderCollection.CountDocuments(ctx, bson.M{"_id": objID, "userId": userId}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrFolderNotFound
+	}
+
+	unfile := func() error {
+		_, err := chatCollection.UpdateMany(ctx,
+			bson.M{"userId": userId, "folderId": folderId},
+			bson.M{"$unset": bson.M{"folderId": ""}},
+		)
+		return err
+	}
+	if err := unfile(); err != nil {
+		return err
+	}
+
+	result, err := folderCollection.DeleteOne(ctx, bson.M{"_id": objID, "userId": userId})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrFolderNotFound
+	}
+	return unfile()
+}
+
+// MoveChatToFolder files a chat under one of its owner's folders, or takes it out
+// of its folder when folderId is empty.
+//
+// There are no transactions to tie the folder check to the move, so the folder is
+// checked again after the chat is filed and the move undone if it was deleted
+// meanwhile. DeleteFolder unfiles chats again after deleting, covering the other order.
+func MoveChatToFolder(userId, chatId, folderId string) error {
+	folderCollection := config.GetCollection(os.Getenv("FOLDER_COLLECTION"))
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"userId": userId, "chatId": chatId}
+	if folderId == "" {
+		result, err := chatCollection.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"folderId": ""}})
+		if err != nil {
+			return fmt.Errorf("failed to move chat: %w", err)
+		}
+		if result.MatchedCount == 0 {
+			return errors.New("user or chat not found")
+		}
+		return nil
+	}
+
+	objID, err := primitive.ObjectIDFromHex(folderId)
+	if err != nil {
+		return ErrFolderNotFound
+	}
+	folderExists := func() (bool, error) {
+		count, err := folderCollection.CountDocuments(ctx, bson.M{"_id": objID, "userId": userId}, options.Count().SetLimit(1))
+		return count > 0, err
+	}
+
+	exists, err := folderExists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrFolderNotFound
+	}
+
+	var before models.Chat
+	err = chatCollection.FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{"folderId": folderId}},
+		options.FindOneAndUpdate().SetProjection(bson.M{"folderId": 1}).SetReturnDocument(options.Before),
+	).Decode(&before)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errors.New("user or chat not found")
+		}
+		return fmt.Errorf("failed to move chat: %w", err)
+	}
+
+	if exists, err = folderExists(); err != nil || exists {
+		return err
+	}
+	// The folder went away while the chat was being filed: put it back where it was,
+	// unless something else has moved it since
+	undo := bson.M{"$unset": bson.M{"folderId": ""}}
+	if before.FolderId != "" && before.FolderId != folderId {
+		undo = bson.M{"$set": bson.M{"folderId": before.FolderId}}
+	}
+	if _, err := chatCollection.UpdateOne(ctx, bson.M{"userId": userId, "chatId": chatId, "folderId": folderId}, undo); err != nil {
+		return fmt.Errorf("failed to undo move into deleted folder: %w", err)
+	}
+	return ErrFolderNotFound
+}
+
+func DeleteUserFolders(userId string) error {
+	folderCollection := config.GetCollection(os.Getenv("FOLDER_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := folderCollection.DeleteMany(ctx, bson.M{"userId": userId})
+	return err
+}
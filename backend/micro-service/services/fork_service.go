@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrForkMessageNotFound = errors.New("message not found in chat")
+
+// relinkedUpload copies an upload into another chat without touching the stored
+// file or its vectors: the copy points at the original through SourceFileId.
+func relinkedUpload(u models.Upload, userId, chatId string) models.Upload {
+	copied := u
+	copied.ID = primitive.NewObjectID()
+	copied.UserId = userId
+	copied.ChatId = chatId
+	copied.WorkspaceId = ""
+
+	// Forks of forks still point at the upload that owns the data
+	if copied.SourceFileId == "" {
+		copied.SourceFileId = u.ID.Hex()
+		copied.SourceUserId = u.UserId
+		copied.SourceChatId = u.ChatId
+	}
+	return copied
+}
+
+// ForkChat branches ownerId's chatId at msgId into a new private chat owned by
+// requesterId. The branch of messages leading to msgId is copied (unflushed ones too),
+// along with the memories; files are re-linked rather than re-uploaded. The
+// summary is left empty for the AI service to regenerate.
+func ForkChat(requesterId, ownerId, chatId, msgId string) (string, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+	fileCollection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var source models.Chat
+	err := chatCollection.FindOne(ctx,
+		bson.M{"userId": ownerId, "chatId": chatId},
+		options.FindOne().SetProjection(bson.M{"name": 1, "memory": 1}),
+	).Decode(&source)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", errors.New("user or chat not found")
+		}
+		return "", err
+	}
+
+	stored, err := LoadChatMessages(ctx, ownerId, chatId)
+	if err != nil {
+		return "", err
+	}
+	pending, err := UnflushedMessages(ctx, ownerId, chatId)
+	if err != nil {
+		return "", err
+	}
+	messages := MergeMessages(stored, pending)
+
+	// The fork follows only the branch leading to msgId, with every variant on it active
+	messages = ThreadPath(messages, msgId)
+	if messages == nil {
+		return "", ErrForkMessageNotFound
+	}
+	for i := range messages {
+		messages[i].Inactive = false
+	}
+
+	cursor, err := fileCollection.Find(ctx, bson.M{"userId": ownerId, "chatId": chatId})
+	if err != nil {
+		return "", err
+	}
+	var uploads []models.Upload
+	if err := cursor.All(ctx, &uploads); err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	fork := models.Chat{
+		UserId:     requesterId,
+		ChatId:     primitive.NewObjectID().Hex(),
+		Name:       source.Name + " (fork)",
+		Memory:     source.Memory,
+		ForkedFrom: &models.ForkOrigin{UserId: ownerId, ChatId: chatId, MsgId: msgId},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if fork.Memory == nil {
+		fork.Memory = []models.Memory{}
+	}
+
+	if _, err := chatCollection.InsertOne(ctx, fork); err != nil {
+		return "", fmt.Errorf("failed to create fork: %w", err)
+	}
+
+	// Undo a partially built fork
+	rollback := func(cause error) (string, error) {
+		_, _ = chatCollection.DeleteOne(ctx, bson.M{"userId": requesterId, "chatId": fork.ChatId})
+		_, _ = fileCollection.DeleteMany(ctx, bson.M{"userId": requesterId, "chatId": fork.ChatId})
+		_ = DeleteChatMessages(ctx, requesterId, fork.ChatId)
+		return "", cause
+	}
+
+	if _, err := AppendChatMessages(ctx, requesterId, fork.ChatId, messages); err != nil {
+		return rollback(fmt.Errorf("failed to copy messages: %w", err))
+	}
+
+	if len(uploads) > 0 {
+		docs := make([]any, 0, len(uploads))
+		for _, u := range uploads {
+			docs = append(docs, relinkedUpload(u, requesterId, fork.ChatId))
+		}
+		if _, err := fileCollection.InsertMany(ctx, docs); err != nil {
+			return rollback(fmt.Errorf("failed to re-link files: %w", err))
+		}
+	}
+
+	return fork.ChatId, nil
+}
+
+// IsSharedUpload reports whether an upload's stored file is shared with other
+// uploads through forking, in which case the last reference cleans it up.
+func IsSharedUpload(u models.Upload) (bool, error) {
+	if u.SourceFileId != "" {
+		return true, nil
+	}
+
+	fileCollection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := fileCollection.CountDocuments(ctx, bson.M{"sourceFileId": u.ID.Hex()}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// MaxImportSize caps an uploaded export (zip or raw JSON) and the JSON inside a zip.
+	MaxImportSize = 50 * 1024 * 1024
+	// MaxImportConversations caps how many conversations one import creates.
+	MaxImportConversations = 500
+)
+
+var ErrAlreadyImported = errors.New("conversation was already imported")
+
+// EnsureImportIndexes creates the index that keeps an export's conversations from
+// being imported twice. Safe to call on every start.
+func EnsureImportIndexes() error {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := chatCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "importSourceId", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"importSourceId": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+// For Import Result, mirroring FileUploadInfo
+type ImportedChatInfo struct {
+	Title    string `json:"title"`
+	SourceId string `json:"sourceId,omitempty"` // conversation id in the export
+	ChatId   string `json:"chatId,omitempty"`
+	Messages int    `json:"messages"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ImportSummary struct {
+	Imported []ImportedChatInfo
+	Skipped  []ImportedChatInfo // imported by an earlier upload
+	Failed   []ImportedChatInfo
+}
+
+// ChatGPT-style export: conversations.json holds an array of conversations, each a
+// tree of message nodes. The visible thread is the path from current_node to the root.
+type exportConversation struct {
+	Id             string                `json:"id"`
+	ConversationId string                `json:"conversation_id"`
+	Title          string                `json:"title"`
+	CreateTime     float64               `json:"create_time"`
+	UpdateTime     float64               `json:"update_time"`
+	CurrentNode    string                `json:"current_node"`
+	Mapping        map[string]exportNode `json:"mapping"`
+}
+
+type exportNode struct {
+	Id      string         `json:"id"`
+	Parent  string         `json:"parent"`
+	Message *exportMessage `json:"message"`
+}
+
+type exportMessage struct {
+	Id     string `json:"id"`
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+	} `json:"content"`
+}
+
+// ReadConversationsExport accepts either conversations.json itself or a zip
+// export containing it. The JSON is decoded as a stream, so only the
+// conversations themselves are held in memory, never the whole upload.
+func ReadConversationsExport(upload io.ReaderAt, size int64) ([]json.RawMessage, error) {
+	var r io.Reader = io.NewSectionReader(upload, 0, size)
+
+	magic := make([]byte, 4)
+	if n, _ := upload.ReadAt(magic, 0); n == len(magic) && bytes.Equal(magic, []byte("PK\x03\x04")) {
+		zr, err := zip.NewReader(upload, size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zip archive: %w", err)
+		}
+		idx := slices.IndexFunc(zr.File, func(f *zip.File) bool { return path.Base(f.Name) == "conversations.json" })
+		if idx < 0 {
+			return nil, errors.New("zip archive has no conversations.json")
+		}
+		if zr.File[idx].UncompressedSize64 > MaxImportSize {
+			return nil, errors.New("conversations.json exceeds import size limit")
+		}
+		f, err := zr.File[idx].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		// The header's size can lie; past the limit the JSON just ends early
+		r = io.LimitReader(f, MaxImportSize)
+	}
+
+	return decodeConversations(r)
+}
+
+// decodeConversations reads a JSON array of conversations, keeping each one raw.
+// Decoded one conversation at a time later, so one bad entry doesn't sink the rest.
+func decodeConversations(r io.Reader) ([]json.RawMessage, error) {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, errors.New("expected a JSON array of conversations")
+	}
+
+	var conversations []json.RawMessage
+	for dec.More() {
+		if len(conversations) == MaxImportConversations {
+			return nil, fmt.Errorf("export has more than %d conversations, split it up", MaxImportConversations)
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("expected a JSON array of conversations: %w", err)
+		}
+		conversations = append(conversations, raw)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of conversations: %w", err)
+	}
+	return conversations, nil
+}
+
+func epochTime(sec float64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
+
+// messageText joins the textual parts of a message; images and other attachments are skipped.
+func messageText(m *exportMessage) string {
+	var parts []string
+	for _, raw := range m.Content.Parts {
+		var s string
+		if json.Unmarshal(raw, &s) == nil && strings.TrimSpace(s) != "" {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 && strings.TrimSpace(m.Content.Text) != "" {
+		parts = append(parts, m.Content.Text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// threadMessages walks current_node back to the root and maps the visible thread
+// onto our roles. System and tool messages are dropped.
+func threadMessages(conv *exportConversation) ([]models.Message, error) {
+	fallback := epochTime(conv.CreateTime)
+	if fallback.IsZero() {
+		fallback = time.Now().UTC()
+	}
+
+	var messages []models.Message
+	visited := map[string]bool{}
+	for id := conv.CurrentNode; id != ""; {
+		if visited[id] {
+			return nil, errors.New("message tree contains a cycle")
+		}
+		visited[id] = true
+
+		node, ok := conv.Mapping[id]
+		if !ok {
+			return nil, fmt.Errorf("message node %q missing from mapping", id)
+		}
+		id = node.Parent
+
+		m := node.Message
+		if m == nil {
+			continue
+		}
+		var role string
+		switch m.Author.Role {
+		case "user":
+			role = "user"
+		case "assistant":
+			role = "ai"
+		default:
+			continue
+		}
+		text := messageText(m)
+		if text == "" {
+			continue
+		}
+
+		// Missing times are filled in from the preceding message once the thread is in order
+		timestamp := ""
+		if ts := epochTime(m.CreateTime); !ts.IsZero() {
+			timestamp = ts.Format(time.RFC3339Nano)
+		}
+		msgId := m.Id
+		if msgId == "" {
+			msgId = primitive.NewObjectID().Hex()
+		}
+		messages = append(messages, models.Message{
+			MsgID:     msgId,
+			Role:      role,
+			Content:   text,
+			Timestamp: timestamp,
+		})
+	}
+
+	slices.Reverse(messages)
+	previous := fallback.Format(time.RFC3339Nano)
+	for i := range messages {
+		if messages[i].Timestamp == "" {
+			messages[i].Timestamp = previous
+		}
+		previous = messages[i].Timestamp
+	}
+	return messages, nil
+}
+
+// importConversation creates one chat from a raw conversation and stores its thread.
+func importConversation(userId string, raw json.RawMessage) (ImportedChatInfo, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	var conv exportConversation
+	if err := json.Unmarshal(raw, &conv); err != nil {
+		return ImportedChatInfo{}, fmt.Errorf("malformed conversation: %w", err)
+	}
+
+	info := ImportedChatInfo{Title: strings.TrimSpace(conv.Title), SourceId: conv.Id}
+	if info.SourceId == "" {
+		info.SourceId = conv.ConversationId
+	}
+	if info.Title == "" {
+		info.Title = "Imported chat"
+	}
+
+	messages, err := threadMessages(&conv)
+	if err != nil {
+		return info, err
+	}
+	if len(messages) == 0 {
+		return info, errors.New("conversation has no user or assistant messages")
+	}
+
+	createdAt := epochTime(conv.CreateTime)
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+	updatedAt := epochTime(conv.UpdateTime)
+	if updatedAt.IsZero() {
+		updatedAt = createdAt
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if info.SourceId != "" {
+		seen, err := chatCollection.CountDocuments(ctx,
+			bson.M{"userId": userId, "importSourceId": info.SourceId},
+			options.Count().SetLimit(1),
+		)
+		if err != nil {
+			return info, err
+		}
+		if seen > 0 {
+			return info, ErrAlreadyImported
+		}
+	}
+
+	chat := models.Chat{
+		UserId:         userId,
+		ChatId:         primitive.NewObjectID().Hex(),
+		Name:           info.Title,
+		Memory:         []models.Memory{},
+		ImportSourceId: info.SourceId,
+		CreatedAt:      createdAt,
+		UpdatedAt:      updatedAt,
+	}
+	if _, err := chatCollection.InsertOne(ctx, chat); err != nil {
+		// A concurrent import of the same export got there first
+		if mongo.IsDuplicateKeyError(err) {
+			return info, ErrAlreadyImported
+		}
+		return info, err
+	}
+
+	stored, err := AppendChatMessages(ctx, userId, chat.ChatId, messages)
+	if err != nil {
+		// Don't leave a half-imported chat behind
+		_, _ = chatCollection.DeleteOne(ctx, bson.M{"userId": userId, "chatId": chat.ChatId})
+		_ = DeleteChatMessages(ctx, userId, chat.ChatId)
+		return info, err
+	}
+
+	info.ChatId = chat.ChatId
+	info.Messages = stored
+	return info, nil
+}
+
+// ImportConversations creates a chat per conversation, reporting each one's outcome.
+func ImportConversations(userId string, conversations []json.RawMessage) ImportSummary {
+	summary := ImportSummary{
+		Imported: []ImportedChatInfo{},
+		Skipped:  []ImportedChatInfo{},
+		Failed:   []ImportedChatInfo{},
+	}
+
+	for _, raw := range conversations {
+		info, err := importConversation(userId, raw)
+		if errors.Is(err, ErrAlreadyImported) {
+			summary.Skipped = append(summary.Skipped, info)
+			continue
+		}
+		if err != nil {
+			info.Error = err.Error()
+			summary.Failed = append(summary.Failed, info)
+			continue
+		}
+		summary.Imported = append(summary.Imported, info)
+	}
+
+	return summary
+}
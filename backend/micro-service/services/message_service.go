@@ -0,0 +1,486 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Messages live one document per message in MESSAGE_COLLECTION, which the AI
+// service reads too, ordered within a chat by seq. The chat document only keeps
+// messageSeq, the last sequence number handed out. Messages migrated from the
+// legacy embedded array sit at seq 0 and below, ahead of everything flushed.
+
+// EnsureMessageIndexes creates the indexes the message paths rely on. Safe to call on every start.
+func EnsureMessageIndexes() error {
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := messageCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "chatId", Value: 1}, {Key: "seq", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// Keeps flushes idempotent: the same msgId is never stored twice
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "chatId", Value: 1}, {Key: "msgId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "chatId", Value: 1}, {Key: "createdAt", Value: 1}},
+		},
+	})
+	return err
+}
+
+// AppendChatMessages stores the messages not yet persisted for a chat, in order,
+// and returns how many were new. The chat document is upserted if missing.
+func AppendChatMessages(ctx context.Context, userId, chatId string, messages []models.Message) (int, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	fresh, err := unstoredMessages(ctx, userId, chatId, messages)
+	if err != nil || len(fresh) == 0 {
+		return 0, err
+	}
+
+	// Reserve a contiguous block of sequence numbers on the chat and refresh its
+	// activity fields, so chat heads can be listed without reading messages
+	last := fresh[len(fresh)-1]
+	var counter struct {
+		MessageSeq int64 `bson:"messageSeq"`
+	}
+	err = chatCollection.FindOneAndUpdate(ctx,
+		bson.M{"userId": userId, "chatId": chatId},
+		bson.M{
+			"$inc": bson.M{"messageSeq": len(fresh), "messageCount": len(fresh)},
+			"$max": bson.M{"updatedAt": messageTime(last)},
+			"$set": bson.M{"lastMessage": PreviewText(last.Content)},
+		},
+		options.FindOneAndUpdate().
+			SetUpsert(true).
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"messageSeq": 1}),
+	).Decode(&counter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve message sequence: %w", err)
+	}
+
+	inserted, err := insertChatMessages(ctx, userId, chatId, fresh, counter.MessageSeq-int64(len(fresh))+1)
+	if err != nil {
+		return 0, err
+	}
+
+	// Messages a concurrent flush stored first were counted above but not inserted.
+	// Their reserved seqs stay unused, which leaves gaps but keeps the order
+	if skipped := len(fresh) - inserted; skipped > 0 {
+		_, err = chatCollection.UpdateOne(ctx,
+			bson.M{"userId": userId, "chatId": chatId},
+			bson.M{"$inc": bson.M{"messageCount": -skipped}},
+		)
+		if err != nil {
+			return inserted, fmt.Errorf("failed to correct message count: %w", err)
+		}
+	}
+
+	return inserted, nil
+}
+
+// unstoredMessages drops the messages already in MESSAGE_COLLECTION, and repeats
+// within messages, keeping the order.
+func unstoredMessages(ctx context.Context, userId, chatId string, messages []models.Message) ([]models.Message, error) {
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.MsgID)
+	}
+
+	cursor, err := messageCollection.Find(ctx,
+		bson.M{"userId": userId, "chatId": chatId, "msgId": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"msgId": 1, "_id": 0}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var existing []models.ChatMessage
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(existing)+len(messages))
+	for _, m := range existing {
+		seen[m.MsgID] = true
+	}
+	fresh := make([]models.Message, 0, len(messages))
+	for _, m := range messages {
+		if seen[m.MsgID] {
+			continue
+		}
+		seen[m.MsgID] = true
+		fresh = append(fresh, m)
+	}
+	return fresh, nil
+}
+
+// insertChatMessages stores messages under consecutive seqs starting at first and
+// returns how many were inserted. Ones a concurrent writer stored first are skipped.
+func insertChatMessages(ctx context.Context, userId, chatId string, messages []models.Message, first int64) (int, error) {
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+
+	docs := make([]any, 0, len(messages))
+	for i, m := range messages {
+		docs = append(docs, models.ChatMessage{
+			UserId:    userId,
+			ChatId:    chatId,
+			Seq:       first + int64(i),
+			Message:   m,
+			CreatedAt: messageTime(m),
+		})
+	}
+
+	_, err := messageCollection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err == nil {
+		return len(docs), nil
+	}
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return 0, err
+	}
+	for _, we := range bulkErr.WriteErrors {
+		if !mongo.IsDuplicateKeyError(we) {
+			return 0, err
+		}
+	}
+	return len(docs) - len(bulkErr.WriteErrors), nil
+}
+
+const previewLength = 120
+
+// PreviewText trims content to a single line of at most previewLength runes.
+func PreviewText(content string) string {
+	preview := []rune(strings.Join(strings.Fields(content), " "))
+	if len(preview) <= previewLength {
+		return string(preview)
+	}
+	return string(preview[:previewLength-1]) + "…"
+}
+
+// messageTime parses a message's timestamp, falling back to now for malformed ones.
+func messageTime(m models.Message) time.Time {
+	if ts, err := time.Parse(time.RFC3339Nano, m.Timestamp); err == nil {
+		return ts.UTC()
+	}
+	return time.Now().UTC()
+}
+
+// LoadChatMessages returns every stored message of a chat in order.
+func LoadChatMessages(ctx context.Context, userId, chatId string) ([]models.Message, error) {
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+
+	cursor, err := messageCollection.Find(ctx,
+		bson.M{"userId": userId, "chatId": chatId},
+		options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []models.ChatMessage
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	messages := make([]models.Message, 0, len(docs))
+	for _, d := range docs {
+		messages = append(messages, d.Message)
+	}
+	return messages, nil
+}
+
+// DeleteChatMessages removes a chat's stored messages.
+func DeleteChatMessages(ctx context.Context, userId, chatId string) error {
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+
+	_, err := messageCollection.DeleteMany(ctx, bson.M{"userId": userId, "chatId": chatId})
+	return err
+}
+
+// MigrateEmbeddedMessages moves one chat's legacy embedded messages array into the
+// messages collection and unsets it. Re-running it on a chat is harmless.
+//
+// The embedded messages predate anything a flush has stored, so they take the seqs
+// just below 1 rather than a block from messageSeq. That keeps them first whether or
+// not the chat was flushed to before migrating, and two runs compute the same seqs.
+func MigrateEmbeddedMessages(ctx context.Context, chat models.Chat) (int, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	// Seqs follow each message's position in the array, so messages copied by an
+	// earlier, interrupted run are skipped as duplicates rather than shifted
+	moved := 0
+	if len(chat.Messages) > 0 {
+		var err error
+		if moved, err = insertChatMessages(ctx, chat.UserId, chat.ChatId, chat.Messages, 1-int64(len(chat.Messages))); err != nil {
+			return 0, err
+		}
+		// messageCount, updatedAt and lastMessage may already reflect newer messages
+		if err := BackfillChatActivity(ctx, chat.UserId, chat.ChatId); err != nil {
+			return moved, errors.Join(errors.New("messages copied but chat activity not updated"), err)
+		}
+	}
+
+	_, err := chatCollection.UpdateOne(ctx,
+		bson.M{"userId": chat.UserId, "chatId": chat.ChatId},
+		bson.M{"$unset": bson.M{"messages": ""}},
+	)
+	if err != nil {
+		return moved, errors.Join(errors.New("messages copied but embedded array not removed"), err)
+	}
+	return moved, nil
+}
+
+// BackfillChatActivity recomputes a chat's messageCount, updatedAt and lastMessage
+// from the messages collection, for chats stored before those fields existed.
+func BackfillChatActivity(ctx context.Context, userId, chatId string) error {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+
+	filter := bson.M{"userId": userId, "chatId": chatId}
+
+	count, err := messageCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	set := bson.M{"messageCount": count}
+	var last models.ChatMessage
+	err = messageCollection.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})).Decode(&last)
+	switch {
+	case err == nil:
+		set["updatedAt"] = last.CreatedAt
+		set["lastMessage"] = PreviewText(last.Content)
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return err
+	}
+
+	_, err = chatCollection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	return err
+}
+
+// UnflushedMessages returns the messages still buffered in the chat's Redis hash,
+// i.e. sent since the last flush to Mongo.
+func UnflushedMessages(ctx context.Context, userId, chatId string) ([]models.Message, error) {
+	raw, err := config.RedisClient.HGet(ctx, fmt.Sprintf("chats:%s:%s", userId, chatId), "messages").Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var messages []models.Message
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &messages); err != nil {
+			return nil, fmt.Errorf("malformed messages in Redis for chatId=%s: %w", chatId, err)
+		}
+	}
+	return messages, nil
+}
+
+// settleAttempts bounds how often SettleFlushedMessages retries after the Redis
+// hash changed underneath it.
+const settleAttempts = 5
+
+// SettleFlushedMessages drops messages a flush has stored from the chat's Redis hash,
+// keeping any that arrived since. A select, edit or regenerate may have changed their
+// variant flags in Redis after the flush read them, possibly before they were in
+// Mongo, so their current flags are written to Mongo first. The hash is watched,
+// and a concurrent change makes the whole step run again rather than be lost.
+//
+// With dropKey the whole hash is deleted in the same transaction, but only if no
+// message is left in it; reports whether it was. A chat still in use keeps its key
+// for the next flush.
+func SettleFlushedMessages(ctx context.Context, userId, chatId string, flushed []models.Message, dropKey bool) (bool, error) {
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+	key := fmt.Sprintf("chats:%s:%s", userId, chatId)
+
+	isFlushed := make(map[string]bool, len(flushed))
+	for _, m := range flushed {
+		isFlushed[m.MsgID] = true
+	}
+
+	dropped := false
+	settle := func(tx *redis.Tx) error {
+		dropped = false
+		raw, err := tx.HGet(ctx, key, "messages").Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		var current []models.Message
+		if raw != "" {
+			if err := json.Unmarshal([]byte(raw), &current); err != nil {
+				return fmt.Errorf("malformed messages in Redis for chatId=%s: %w", chatId, err)
+			}
+		}
+
+		remaining := make([]models.Message, 0, len(current))
+		var activate, deactivate []string
+		for _, m := range current {
+			switch {
+			case !isFlushed[m.MsgID]:
+				remaining = append(remaining, m)
+			case m.Inactive:
+				deactivate = append(deactivate, m.MsgID)
+			default:
+				activate = append(activate, m.MsgID)
+			}
+		}
+
+		filter := bson.M{"userId": userId, "chatId": chatId}
+		if len(deactivate) > 0 {
+			filter["msgId"] = bson.M{"$in": deactivate}
+			if _, err := messageCollection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"inactive": true}}); err != nil {
+				return err
+			}
+		}
+		if len(activate) > 0 {
+			filter["msgId"] = bson.M{"$in": activate}
+			if _, err := messageCollection.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"inactive": ""}}); err != nil {
+				return err
+			}
+		}
+
+		if dropKey && len(remaining) == 0 {
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Del(ctx, key)
+				return nil
+			})
+			dropped = err == nil
+			return err
+		}
+		if raw == "" && len(remaining) == 0 {
+			return nil
+		}
+		data, err := json.Marshal(remaining)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, "messages", data)
+			return nil
+		})
+		return err
+	}
+
+	for range settleAttempts {
+		err := config.RedisClient.Watch(ctx, settle, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return dropped, err
+		}
+	}
+	return false, fmt.Errorf("redis hash for chatId=%s kept changing: %w", chatId, redis.TxFailedErr)
+}
+
+// MergeMessages appends the pending messages missing from stored, keeping order
+// and dropping duplicates by msgId. A message in both keeps its Redis copy, whose
+// variant flags may be newer.
+func MergeMessages(stored, pending []models.Message) []models.Message {
+	index := make(map[string]int, len(stored))
+	for i, m := range stored {
+		index[m.MsgID] = i
+	}
+
+	merged := stored
+	for _, m := range pending {
+		if i, ok := index[m.MsgID]; ok {
+			merged[i] = m
+			continue
+		}
+		index[m.MsgID] = len(merged)
+		merged = append(merged, m)
+	}
+	return merged
+}
+
+// messageParents resolves the parent of every message in a chat's history, oldest
+// first. Messages stored before branching existed follow the message before them.
+func messageParents(messages []models.Message) ([]string, map[string]int) {
+	parents := make([]string, len(messages))
+	index := make(map[string]int, len(messages))
+	for i, m := range messages {
+		switch {
+		case m.ParentId != "":
+			parents[i] = m.ParentId
+		case i > 0:
+			parents[i] = messages[i-1].MsgID
+		default:
+			parents[i] = models.ThreadRoot
+		}
+		index[m.MsgID] = i
+	}
+	return parents, index
+}
+
+// messagePath walks from messages[i] back to the start of the chat and returns the
+// path oldest first.
+func messagePath(messages []models.Message, parents []string, index map[string]int, i int) []models.Message {
+	var path []models.Message
+	for i >= 0 {
+		path = append(path, messages[i])
+		p, ok := index[parents[i]]
+		if !ok || p >= i {
+			break
+		}
+		i = p
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// ActiveBranch returns the path through a chat's edit and regenerate variants that
+// the conversation currently follows: the messages that are neither Inactive nor
+// descended from an Inactive one.
+func ActiveBranch(messages []models.Message) []models.Message {
+	parents, index := messageParents(messages)
+	active := make([]bool, len(messages))
+	leaf := -1
+	for i, m := range messages {
+		p, ok := index[parents[i]]
+		active[i] = !m.Inactive && (!ok || p >= i || active[p])
+		if active[i] {
+			leaf = i
+		}
+	}
+	if leaf < 0 {
+		return nil
+	}
+	return messagePath(messages, parents, index, leaf)
+}
+
+// ThreadPath returns the messages leading up to and including msgId, oldest first,
+// or nil if the chat has no such message.
+func ThreadPath(messages []models.Message, msgId string) []models.Message {
+	parents, index := messageParents(messages)
+	i, ok := index[msgId]
+	if !ok {
+		return nil
+	}
+	return messagePath(messages, parents, index, i)
+}
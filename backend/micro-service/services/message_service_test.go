@@ -0,0 +1,116 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+)
+
+func msg(id, parent string, inactive bool) models.Message {
+	return models.Message{MsgID: id, ParentId: parent, Inactive: inactive}
+}
+
+func msgIds(messages []models.Message) []string {
+	var ids []string
+	for _, m := range messages {
+		ids = append(ids, m.MsgID)
+	}
+	return ids
+}
+
+func TestActiveBranch(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []models.Message
+		want     []string
+	}{
+		{
+			name:     "legacy messages follow the one before them",
+			messages: []models.Message{msg("u1", "", false), msg("a1", "", false), msg("u2", "", false), msg("a2", "", false)},
+			want:     []string{"u1", "a1", "u2", "a2"},
+		},
+		{
+			name: "branched messages after legacy ones",
+			messages: []models.Message{
+				msg("u1", "", false), msg("a1", "", false),
+				msg("u2", "a1", false), msg("a2", "u2", true), msg("a2b", "u2", false),
+			},
+			want: []string{"u1", "a1", "u2", "a2b"},
+		},
+		{
+			name: "window starts mid-chat",
+			messages: []models.Message{
+				msg("u5", "a4", false), msg("a5", "u5", false), msg("u6", "a5", false), msg("a6", "u6", false),
+			},
+			want: []string{"u5", "a5", "u6", "a6"},
+		},
+		{
+			name: "edit of the first message",
+			messages: []models.Message{
+				msg("u1", models.ThreadRoot, true), msg("a1", "u1", false),
+				msg("u1b", models.ThreadRoot, false), msg("a1b", "u1b", false),
+			},
+			want: []string{"u1b", "a1b"},
+		},
+		{
+			name: "older variant selected again",
+			messages: []models.Message{
+				msg("u1", models.ThreadRoot, false), msg("a1", "u1", false),
+				msg("u1b", models.ThreadRoot, true), msg("a1b", "u1b", false),
+			},
+			want: []string{"u1", "a1"},
+		},
+		{
+			name: "regenerated reply",
+			messages: []models.Message{
+				msg("u1", models.ThreadRoot, false), msg("a1", "u1", true), msg("a1b", "u1", false),
+			},
+			want: []string{"u1", "a1b"},
+		},
+		{
+			name:     "nothing active",
+			messages: []models.Message{msg("u1", models.ThreadRoot, true), msg("a1", "u1", false)},
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := msgIds(ActiveBranch(tt.messages)); !slices.Equal(got, tt.want) {
+				t.Errorf("ActiveBranch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreadPath(t *testing.T) {
+	messages := []models.Message{
+		msg("u1", "", false), msg("a1", "", false),
+		msg("u2", "a1", true), msg("a2", "u2", false),
+		msg("u2b", "a1", false), msg("a2b", "u2b", true), msg("a2c", "u2b", false),
+	}
+	window := []models.Message{msg("u5", "a4", false), msg("a5", "u5", false)}
+
+	tests := []struct {
+		name     string
+		messages []models.Message
+		msgId    string
+		want     []string
+	}{
+		{name: "first legacy message", messages: messages, msgId: "u1", want: []string{"u1"}},
+		{name: "legacy parent", messages: messages, msgId: "a1", want: []string{"u1", "a1"}},
+		{name: "inactive edit", messages: messages, msgId: "a2", want: []string{"u1", "a1", "u2", "a2"}},
+		{name: "inactive regenerated reply", messages: messages, msgId: "a2b", want: []string{"u1", "a1", "u2b", "a2b"}},
+		{name: "window starts mid-chat", messages: window, msgId: "a5", want: []string{"u5", "a5"}},
+		{name: "unknown message", messages: messages, msgId: "missing", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := msgIds(ThreadPath(tt.messages, tt.msgId)); !slices.Equal(got, tt.want) {
+				t.Errorf("ThreadPath(%q) = %v, want %v", tt.msgId, got, tt.want)
+			}
+		})
+	}
+}
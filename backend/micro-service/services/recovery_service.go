@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/mailer"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Redis layout: single-use tokens mapping sha256(token) -> userId, expiring on their own.
+//
+//	auth:reset:<sha256(token)>
+//	auth:verify:<sha256(token)>
+func resetKey(tokenHash string) string  { return "auth:reset:" + tokenHash }
+func verifyKey(tokenHash string) string { return "auth:verify:" + tokenHash }
+
+func appBaseURL() string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = "http://localhost:3100"
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// mintEmailToken stores a fresh single-use token for userId under keyFn and returns it.
+func mintEmailToken(ctx context.Context, keyFn func(string) string, userId string, ttl time.Duration) (string, error) {
+	token, err := newRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	if err := config.RedisClient.Set(ctx, keyFn(hashToken(token)), userId, ttl).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeEmailToken returns the userId stored for token and deletes it.
+func consumeEmailToken(ctx context.Context, keyFn func(string) string, token string) (string, error) {
+	userId, err := config.RedisClient.GetDel(ctx, keyFn(hashToken(token))).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", errors.New("token is invalid or expired")
+		}
+		return "", err
+	}
+	return userId, nil
+}
+
+// SendVerificationEmail mails a link that marks the user's email as verified.
+func SendVerificationEmail(userId, email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	ttl := durationFromEnv("VERIFY_TOKEN_TTL", 24*time.Hour)
+	token, err := mintEmailToken(ctx, verifyKey, userId, ttl)
+	if err != nil {
+		return err
+	}
+
+	return config.Mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your MemoryLane email",
+		Body: fmt.Sprintf("Welcome to MemoryLane!\n\nConfirm your email address by opening the link below (valid for %s):\n\n%s/verify-email?token=%s\n",
+			ttl, appBaseURL(), token),
+	})
+}
+
+// ResendVerificationEmail re-sends the verification link unless already verified.
+func ResendVerificationEmail(userId string) error {
+	user, err := findUserById(userId)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return errors.New("email is already verified")
+	}
+	return SendVerificationEmail(userId, user.Email)
+}
+
+func VerifyEmail(token string) error {
+	userCollection := config.GetCollection(os.Getenv("AUTH_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userId, err := consumeEmailToken(ctx, verifyKey, token)
+	if err != nil {
+		return err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	res, err := userCollection.UpdateOne(ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{"emailVerified": true, "emailVerifiedAt": time.Now().UTC()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// RequestPasswordReset mails a reset link if the email is registered. It never
+// reveals whether the email exists.
+func RequestPasswordReset(email string) error {
+	userCollection := config.GetCollection(os.Getenv("AUTH_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("[RequestPasswordReset (Auth Service)] No reset sent for unknown email")
+			return nil
+		}
+		log.Printf("[RequestPasswordReset (Auth Service)] User lookup failed: %v", err)
+		return err
+	}
+
+	ttl := durationFromEnv("RESET_TOKEN_TTL", time.Hour)
+	token, err := mintEmailToken(ctx, resetKey, user.ID.Hex(), ttl)
+	if err != nil {
+		return err
+	}
+
+	return config.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your MemoryLane password",
+		Body: fmt.Sprintf("Someone asked to reset the password for this account.\n\nOpen the link below to choose a new one (valid for %s):\n\n%s/reset-password?token=%s\n\nIf this wasn't you, you can ignore this email.\n",
+			ttl, appBaseURL(), token),
+	})
+}
+
+// ConfirmPasswordReset sets a new password and logs the user out everywhere.
+func ConfirmPasswordReset(token, newPassword string) error {
+	if len(newPassword) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+
+	userCollection := config.GetCollection(os.Getenv("AUTH_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userId, err := consumeEmailToken(ctx, resetKey, token)
+	if err != nil {
+		return err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	hashed, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	// Receiving the link proves control of the inbox, so the email counts as verified too.
+	var user models.User
+	err = userCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{"password": hashed, "emailVerified": true}},
+	).Decode(&user)
+	if err != nil {
+		return err
+	}
+
+	if _, err := LogoutEverywhere(userId); err != nil {
+		log.Printf("[ConfirmPasswordReset (Auth Service)] ERROR revoking sessions for userId=%s: %v", userId, err)
+	}
+	if err := UnlockLogin(user.Email, ""); err != nil {
+		log.Printf("[ConfirmPasswordReset (Auth Service)] ERROR clearing lockout for userId=%s: %v", userId, err)
+	}
+
+	return nil
+}
+
+func findUserById(userId string) (*models.User, error) {
+	objID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := FindExactlyOne[models.User](os.Getenv("AUTH_COLLECTION"), bson.M{"_id": objID})
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	return &user, nil
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+
+	snippetRadius = 60 // runes of context kept either side of the first match
+)
+
+var ErrEmptyQuery = errors.New("search query is required")
+
+// EnsureSearchIndexes creates the text indexes search runs on. Mongo allows one
+// text index per collection, so chat names and memory contexts share one.
+func EnsureSearchIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := config.GetCollection(os.Getenv("MESSAGE_COLLECTION")).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "content", Value: "text"}},
+		Options: options.Index().SetName("message_text"),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = config.GetCollection(os.Getenv("CHAT_COLLECTION")).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "name", Value: "text"}, {Key: "memory.context", Value: "text"}},
+		Options: options.Index().
+			SetName("chat_text").
+			SetWeights(bson.M{"name": 3, "memory.context": 1}),
+	})
+	return err
+}
+
+// searchTerms splits a query into lowercase terms for highlighting and Redis matching.
+func searchTerms(q string) []string {
+	fields := strings.Fields(strings.ToLower(q))
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f = strings.Trim(f, `"'-`)
+		if f != "" {
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+// matchedTerms reports how many distinct terms occur in text.
+func matchedTerms(text string, terms []string) int {
+	lower := strings.ToLower(text)
+	n := 0
+	for _, t := range terms {
+		if strings.Contains(lower, t) {
+			n++
+		}
+	}
+	return n
+}
+
+// termPattern matches any of terms case-insensitively, preferring the longest
+// where several start at the same place.
+func termPattern(terms []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if t != "" {
+			quoted = append(quoted, regexp.QuoteMeta(t))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// highlight cuts a snippet around the first matching term and wraps every match in <mark>.
+// Everything else is HTML-escaped so the snippet can be rendered as-is.
+func highlight(text string, terms []string) string {
+	text = strings.Join(strings.Fields(text), " ")
+
+	// Matching on text itself keeps offsets valid even where case folding
+	// would change a character's byte length
+	var matches [][]int
+	if pattern := termPattern(terms); pattern != nil {
+		matches = pattern.FindAllStringIndex(text, -1)
+	}
+	first := 0
+	if len(matches) > 0 {
+		first = matches[0][0]
+	}
+
+	// Window in runes around the first match
+	startRune := max(utf8.RuneCountInString(text[:first])-snippetRadius, 0)
+	runes := []rune(text)
+	endRune := min(startRune+2*snippetRadius, len(runes))
+	start := len(string(runes[:startRune]))
+	end := len(string(runes[:endRune]))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	at := start
+	for _, m := range matches {
+		// Matches cut by the window edges are marked only for the part inside it
+		from, to := max(m[0], start), min(m[1], end)
+		if from >= to {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[at:from]))
+		b.WriteString("<mark>" + html.EscapeString(text[from:to]) + "</mark>")
+		at = to
+	}
+	b.WriteString(html.EscapeString(text[at:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// Search ranks message, chat-name and memory hits across every chat the user can
+// read, including messages still waiting in Redis to be flushed.
+func Search(userId, query string, limit int) ([]models.SearchHit, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+	messageCollection := config.GetCollection(os.Getenv("MESSAGE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query = strings.TrimSpace(query)
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	// Own chats plus chats shared through the user's workspaces
+	workspaceIds, err := MemberWorkspaceIds(userId)
+	if err != nil {
+		return nil, err
+	}
+	scope := bson.M{
+		"$or": bson.A{
+			bson.M{"userId": userId},
+			bson.M{"workspaceId": bson.M{"$in": workspaceIds}},
+		},
+		"deletedAt": bson.M{"$exists": false},
+	}
+
+	cursor, err := chatCollection.Find(ctx, scope, options.Find().SetProjection(bson.M{
+		"userId": 1,
+		"chatId": 1,
+		"name":   1,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	var chats []models.Chat
+	if err := cursor.All(ctx, &chats); err != nil {
+		return nil, err
+	}
+	if len(chats) == 0 {
+		return []models.SearchHit{}, nil
+	}
+
+	byId := make(map[string]models.Chat, len(chats))
+	chatIds := make([]string, 0, len(chats))
+	for _, chat := range chats {
+		byId[chat.ChatId] = chat
+		chatIds = append(chatIds, chat.ChatId)
+	}
+
+	var hits []models.SearchHit
+	seen := map[string]bool{} // chatId/msgId pairs already hit, so Redis copies of flushed messages are skipped
+
+	// Messages
+	textScore := bson.M{"score": bson.M{"$meta": "textScore"}}
+	cursor, err = messageCollection.Find(ctx,
+		bson.M{"$text": bson.M{"$search": query}, "chatId": bson.M{"$in": chatIds}},
+		options.Find().
+			SetProjection(bson.M{"userId": 1, "chatId": 1, "msgId": 1, "role": 1, "content": 1, "score": bson.M{"$meta": "textScore"}}).
+			SetSort(textScore).
+			SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("message search failed: %w", err)
+	}
+	var messageDocs []struct {
+		models.ChatMessage `bson:",inline"`
+		Score              float64 `bson:"score"`
+	}
+	if err := cursor.All(ctx, &messageDocs); err != nil {
+		return nil, err
+	}
+	for _, d := range messageDocs {
+		chat, ok := byId[d.ChatId]
+		if !ok || chat.UserId != d.UserId {
+			continue
+		}
+		seen[d.ChatId+"/"+d.MsgID] = true
+		hits = append(hits, models.SearchHit{
+			Kind:     models.HitMessage,
+			ChatId:   d.ChatId,
+			OwnerId:  d.UserId,
+			ChatName: chat.Name,
+			MsgId:    d.MsgID,
+			Role:     d.Role,
+			Snippet:  highlight(d.Content, terms),
+			Score:    d.Score,
+		})
+	}
+
+	// Chat names and memories share a text index; work out which part matched
+	chatFilter := bson.M{"$text": bson.M{"$search": query}}
+	for k, v := range scope {
+		chatFilter[k] = v
+	}
+	cursor, err = chatCollection.Find(ctx, chatFilter,
+		options.Find().
+			SetProjection(bson.M{"userId": 1, "chatId": 1, "name": 1, "memory": 1, "score": bson.M{"$meta": "textScore"}}).
+			SetSort(textScore).
+			SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("chat search failed: %w", err)
+	}
+	var chatDocs []struct {
+		models.Chat `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+	if err := cursor.All(ctx, &chatDocs); err != nil {
+		return nil, err
+	}
+	for _, d := range chatDocs {
+		if matchedTerms(d.Name, terms) > 0 {
+			hits = append(hits, models.SearchHit{
+				Kind:     models.HitChat,
+				ChatId:   d.ChatId,
+				OwnerId:  d.UserId,
+				ChatName: d.Name,
+				Snippet:  highlight(d.Name, terms),
+				Score:    d.Score,
+			})
+		}
+		for _, mem := range d.Memory {
+			if matchedTerms(mem.Context, terms) == 0 {
+				continue
+			}
+			hits = append(hits, models.SearchHit{
+				Kind:     models.HitMemory,
+				ChatId:   d.ChatId,
+				OwnerId:  d.UserId,
+				ChatName: d.Name,
+				MemId:    mem.Memid,
+				Snippet:  highlight(mem.Context, terms),
+				Score:    d.Score,
+			})
+		}
+	}
+
+	// Unflushed messages
+	redisHits, err := searchRedisMessages(ctx, chats, terms, seen)
+	if err != nil {
+		return nil, err
+	}
+	hits = append(hits, redisHits...)
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	if hits == nil {
+		hits = []models.SearchHit{}
+	}
+	return hits, nil
+}
+
+// searchRedisMessages scans each chat's chats:<ownerId>:<chatId> hash for messages
+// containing any term. They get no textScore, so one is approximated from how
+// many terms they contain; they are the most recent messages, so rank them high.
+func searchRedisMessages(ctx context.Context, chats []models.Chat, terms []string, seen map[string]bool) ([]models.SearchHit, error) {
+	pipe := config.RedisClient.Pipeline()
+	cmds := make([]*redis.StringCmd, 0, len(chats))
+	for _, chat := range chats {
+		cmds = append(cmds, pipe.HGet(ctx, fmt.Sprintf("chats:%s:%s", chat.UserId, chat.ChatId), "messages"))
+	}
+	// redis.Nil for chats without a live hash is expected
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	var hits []models.SearchHit
+	for i, cmd := range cmds {
+		raw := cmd.Val()
+		if raw == "" {
+			continue
+		}
+
+		var messages []models.Message
+		if err := json.Unmarshal([]byte(raw), &messages); err != nil {
+			continue
+		}
+
+		chat := chats[i]
+		for _, m := range messages {
+			key := chat.ChatId + "/" + m.MsgID
+			if seen[key] {
+				continue
+			}
+			n := matchedTerms(m.Content, terms)
+			if n == 0 {
+				continue
+			}
+			seen[key] = true
+			hits = append(hits, models.SearchHit{
+				Kind:      models.HitMessage,
+				ChatId:    chat.ChatId,
+				OwnerId:   chat.UserId,
+				ChatName:  chat.Name,
+				MsgId:     m.MsgID,
+				Role:      m.Role,
+				Snippet:   highlight(m.Content, terms),
+				Score:     1.0 + float64(n)/float64(len(terms)),
+				Unflushed: true,
+			})
+		}
+	}
+	return hits, nil
+}
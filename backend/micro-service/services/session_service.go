@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+// Redis layout:
+//
+//	auth:session:<sid>           hash {userId, email, refreshHash}, expires with the refresh token
+//	auth:refresh:<sha256(token)> sid of the session owning that refresh token
+//	auth:user_sessions:<userId>  set of the user's live sids
+//	auth:revoked:<sid>           present while access tokens of a logged-out session may still be unexpired
+func sessionKey(sid string) string         { return "auth:session:" + sid }
+func refreshKey(tokenHash string) string   { return "auth:refresh:" + tokenHash }
+func userSessionsKey(userId string) string { return "auth:user_sessions:" + userId }
+func revokedKey(sid string) string         { return "auth:revoked:" + sid }
+
+type TokenPair struct {
+	AccessToken      string    `json:"accessToken"`
+	AccessExpiresAt  time.Time `json:"expiresAt"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokenPair mints a fresh refresh token for sid and an access token bound to it.
+func issueTokenPair(ctx context.Context, sid, userId, email string) (*TokenPair, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	refreshTTL := durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+	tokenHash := hashToken(refreshToken)
+
+	_, err = config.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionKey(sid), map[string]any{
+			"userId":      userId,
+			"email":       email,
+			"refreshHash": tokenHash,
+		})
+		pipe.Expire(ctx, sessionKey(sid), refreshTTL)
+		pipe.Set(ctx, refreshKey(tokenHash), sid, refreshTTL)
+		pipe.SAdd(ctx, userSessionsKey(userId), sid)
+		pipe.Expire(ctx, userSessionsKey(userId), refreshTTL)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store session: %w", err)
+	}
+
+	accessToken, accessExpiresAt, err := IssueAccessToken(userId, email, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: time.Now().UTC().Add(refreshTTL),
+	}, nil
+}
+
+// StartSession creates a new device session after a successful login.
+func StartSession(userId, email string) (*TokenPair, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return issueTokenPair(ctx, primitive.NewObjectID().Hex(), userId, email)
+}
+
+// lookupSession resolves a refresh token to its session id and stored fields. With
+// consume the token is deleted in the same step, so only one caller can ever use it.
+func lookupSession(ctx context.Context, refreshToken string, consume bool) (string, map[string]string, error) {
+	tokenHash := hashToken(refreshToken)
+
+	get := config.RedisClient.Get
+	if consume {
+		get = config.RedisClient.GetDel
+	}
+	sid, err := get(ctx, refreshKey(tokenHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil, errors.New("refresh token is invalid or expired")
+		}
+		return "", nil, err
+	}
+
+	session, err := config.RedisClient.HGetAll(ctx, sessionKey(sid)).Result()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(session) == 0 || session["refreshHash"] != tokenHash {
+		return "", nil, errors.New("refresh token is invalid or expired")
+	}
+
+	return sid, session, nil
+}
+
+// RefreshSession rotates the refresh token of a session and issues a new access token.
+func RefreshSession(refreshToken string) (*TokenPair, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The old refresh token is single use: a concurrent refresh with it finds nothing.
+	sid, session, err := lookupSession(ctx, refreshToken, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return issueTokenPair(ctx, sid, session["userId"], session["email"])
+}
+
+// revokeSessions deletes the given sessions and blacklists their outstanding access tokens.
+func revokeSessions(ctx context.Context, userId string, sids []string) error {
+	accessTTL := durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+
+	for _, sid := range sids {
+		refreshHash, err := config.RedisClient.HGet(ctx, sessionKey(sid), "refreshHash").Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		_, err = config.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if refreshHash != "" {
+				pipe.Del(ctx, refreshKey(refreshHash))
+			}
+			pipe.Del(ctx, sessionKey(sid))
+			pipe.SRem(ctx, userSessionsKey(userId), sid)
+			pipe.Set(ctx, revokedKey(sid), 1, accessTTL)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to revoke session %s: %w", sid, err)
+		}
+	}
+
+	return nil
+}
+
+// Logout revokes only the session (device) that owns the given refresh token.
+func Logout(refreshToken string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sid, session, err := lookupSession(ctx, refreshToken, false)
+	if err != nil {
+		return err
+	}
+
+	return revokeSessions(ctx, session["userId"], []string{sid})
+}
+
+// LogoutEverywhere revokes every session of the user.
+func LogoutEverywhere(userId string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sids, err := config.RedisClient.SMembers(ctx, userSessionsKey(userId)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := revokeSessions(ctx, userId, sids); err != nil {
+		return 0, err
+	}
+
+	return len(sids), nil
+}
+
+// IsSessionRevoked reports whether access tokens carrying sid must be rejected.
+func IsSessionRevoked(sid string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	n, err := config.RedisClient.Exists(ctx, revokedKey(sid)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
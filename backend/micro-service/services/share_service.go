@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ShareTokenPrefix marks share tokens so they are recognisable in URLs and logs.
+const ShareTokenPrefix = "shr_"
+
+var (
+	ErrShareNotFound   = errors.New("share link not found or no longer active")
+	ErrShareExpiryPast = errors.New("expiresAt must be in the future")
+)
+
+// EnsureShareIndexes creates the indexes the share paths rely on. Safe to call on every start.
+func EnsureShareIndexes() error {
+	shareCollection := config.GetCollection(os.Getenv("SHARE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := shareCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "chatId", Value: 1}},
+		},
+	})
+	return err
+}
+
+// CreateShareLink snapshots the chat's active branch and mints a public token for it.
+// The raw token is only returned here.
+func CreateShareLink(userId, chatId string, input models.ShareLinkInput) (string, *models.ShareLink, error) {
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return "", nil, ErrShareExpiryPast
+	}
+
+	snapshot, err := BuildChatExport(userId, chatId)
+	if err != nil {
+		return "", nil, err
+	}
+	// What produced each reply stays private, whatever the redaction settings
+	for i := range snapshot.Messages {
+		snapshot.Messages[i].Generation = nil
+		snapshot.Messages[i].Inactive = false
+	}
+	if input.RedactMemories {
+		snapshot.Memories = []models.Memory{}
+	}
+	if input.RedactFileNames {
+		for i := range snapshot.Files {
+			snapshot.Files[i].FileName = "[redacted]"
+		}
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	rawToken := ShareTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	link := models.ShareLink{
+		ID:              primitive.NewObjectID(),
+		UserId:          userId,
+		ChatId:          chatId,
+		Prefix:          rawToken[:len(ShareTokenPrefix)+6],
+		TokenHash:       hashToken(rawToken),
+		RedactMemories:  input.RedactMemories,
+		RedactFileNames: input.RedactFileNames,
+		Snapshot:        *snapshot,
+		CreatedAt:       time.Now().UTC(),
+		ExpiresAt:       input.ExpiresAt,
+	}
+
+	shareCollection := config.GetCollection(os.Getenv("SHARE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := shareCollection.InsertOne(ctx, link); err != nil {
+		return "", nil, err
+	}
+	return rawToken, &link, nil
+}
+
+// activeShareFilter matches links that are neither revoked nor expired.
+func activeShareFilter(filter bson.M) bson.M {
+	filter["revokedAt"] = bson.M{"$exists": false}
+	filter["$or"] = bson.A{
+		bson.M{"expiresAt": bson.M{"$exists": false}},
+		bson.M{"expiresAt": bson.M{"$gt": time.Now().UTC()}},
+	}
+	return filter
+}
+
+// ListShareLinks returns the user's active share links, newest first.
+func ListShareLinks(userId string) ([]models.ShareLink, error) {
+	shareCollection := config.GetCollection(os.Getenv("SHARE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := shareCollection.Find(ctx,
+		activeShareFilter(bson.M{"userId": userId}),
+		options.Find().
+			SetProjection(bson.M{"snapshot": 0}).
+			SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	links := []models.ShareLink{}
+	if err := cursor.All(ctx, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func RevokeShareLink(userId, shareId string) error {
+	shareCollection := config.GetCollection(os.Getenv("SHARE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(shareId)
+	if err != nil {
+		return ErrShareNotFound
+	}
+
+	res, err := shareCollection.UpdateOne(ctx,
+		bson.M{"_id": objID, "userId": userId, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now().UTC()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// GetSharedChat resolves a raw share token to its snapshot and counts the view.
+// Links to chats that have since been trashed or deleted stop resolving.
+func GetSharedChat(rawToken string) (*models.ChatExport, error) {
+	shareCollection := config.GetCollection(os.Getenv("SHARE_COLLECTION"))
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var link models.ShareLink
+	err := shareCollection.FindOneAndUpdate(ctx,
+		activeShareFilter(bson.M{"tokenHash": hashToken(rawToken)}),
+		bson.M{
+			"$set": bson.M{"lastViewedAt": time.Now().UTC()},
+			"$inc": bson.M{"viewCount": 1},
+		},
+	).Decode(&link)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrShareNotFound
+		}
+		return nil, err
+	}
+
+	live, err := chatCollection.CountDocuments(ctx,
+		bson.M{"userId": link.UserId, "chatId": link.ChatId, "deletedAt": bson.M{"$exists": false}},
+		options.Count().SetLimit(1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if live == 0 {
+		return nil, ErrShareNotFound
+	}
+	return &link.Snapshot, nil
+}
+
+// DeleteChatShareLinks removes every share link to a chat.
+func DeleteChatShareLinks(ctx context.Context, userId, chatId string) error {
+	shareCollection := config.GetCollection(os.Getenv("SHARE_COLLECTION"))
+	_, err := shareCollection.DeleteMany(ctx, bson.M{"userId": userId, "chatId": chatId})
+	return err
+}
+
+// DeleteUserShareLinks removes every share link a user created.
+func DeleteUserShareLinks(userId string) error {
+	shareCollection := config.GetCollection(os.Getenv("SHARE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := shareCollection.DeleteMany(ctx, bson.M{"userId": userId})
+	return err
+}
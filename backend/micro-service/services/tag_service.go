@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	MaxChatTags      = 20
+	MaxChatTagLength = 32
+)
+
+var (
+	ErrInvalidTag  = fmt.Errorf("tags must be 1-%d characters", MaxChatTagLength)
+	ErrTooManyTags = fmt.Errorf("a chat can have at most %d tags", MaxChatTags)
+)
+
+// CleanTag normalizes a tag to its stored form: trimmed and lowercased.
+func CleanTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxChatTagLength {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+func cleanTags(tags []string) (bson.A, error) {
+	out := bson.A{}
+	for _, tag := range tags {
+		clean, err := CleanTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(out, any(clean)) {
+			out = append(out, clean)
+		}
+	}
+	return out, nil
+}
+
+// UpdateChatTags applies tag additions and removals to a chat in a single update
+// and returns the chat's tags afterwards.
+func UpdateChatTags(userId, chatId string, update models.ChatTagsUpdate) ([]string, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	add, err := cleanTags(update.Add)
+	if err != nil {
+		return nil, err
+	}
+	remove, err := cleanTags(update.Remove)
+	if err != nil {
+		return nil, err
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		return nil, errors.New("nothing to update")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tags := bson.M{"$setUnion": bson.A{
+		bson.M{"$setDifference": bson.A{bson.M{"$ifNull": bson.A{"$tags", bson.A{}}}, bson.M{"$literal": remove}}},
+		bson.M{"$literal": add},
+	}}
+	// Tags are user input, hence $literal: one starting with "$" must not read as a field path.
+	// The size check sits in the filter so the limit holds under concurrent edits
+	filter := bson.M{
+		"userId": userId,
+		"chatId": chatId,
+		"$expr":  bson.M{"$lte": bson.A{bson.M{"$size": tags}, MaxChatTags}},
+	}
+
+	var chat models.Chat
+	err = chatCollection.FindOneAndUpdate(ctx, filter,
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"tags": tags}}}},
+		options.FindOneAndUpdate().SetProjection(bson.M{"tags": 1}).SetReturnDocument(options.After),
+	).Decode(&chat)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("failed to update tags: %w", err)
+		}
+		exists, err := ChatExists(userId, chatId)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrTooManyTags
+		}
+		return nil, errors.New("user or chat not found")
+	}
+
+	slices.Sort(chat.Tags)
+	if chat.Tags == nil {
+		chat.Tags = []string{}
+	}
+	return chat.Tags, nil
+}
+
+// ListTags returns the tags on the user's live chats, most used first.
+func ListTags(userId string) ([]models.TagCount, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := chatCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"userId": userId, "deletedAt": bson.M{"$exists": false}}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags := []models.TagCount{}
+	if err := cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// RenameTag replaces a tag on every chat of the user's that has it, merging it into
+// newTag where both are present. Returns how many chats changed.
+func RenameTag(userId, tag, newTag string) (int64, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	tag, err := CleanTag(tag)
+	if err != nil {
+		return 0, err
+	}
+	newTag, err = CleanTag(newTag)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := chatCollection.UpdateMany(ctx,
+		bson.M{"userId": userId, "tags": tag},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"tags": bson.M{"$setUnion": bson.A{
+			bson.M{"$setDifference": bson.A{"$tags", bson.M{"$literal": bson.A{tag}}}},
+			bson.M{"$literal": bson.A{newTag}},
+		}}}}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// DeleteTag removes a tag from every chat of the user's. Returns how many chats changed.
+func DeleteTag(userId, tag string) (int64, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	tag, err := CleanTag(tag)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := chatCollection.UpdateMany(ctx,
+		bson.M{"userId": userId, "tags": tag},
+		bson.M{"$pull": bson.M{"tags": tag}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
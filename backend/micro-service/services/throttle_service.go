@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Published through expvar and served on /admin/metrics.
+var throttleMetrics = expvar.NewMap("auth_throttle")
+
+// ThrottleError is returned when a caller has to back off before retrying.
+type ThrottleError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%s, retry after %ds", e.Reason, e.retryAfterSeconds())
+}
+
+func (e *ThrottleError) retryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// RetryAfterSeconds is the value for the Retry-After header.
+func (e *ThrottleError) RetryAfterSeconds() string {
+	return strconv.Itoa(max(e.retryAfterSeconds(), 1))
+}
+
+func intFromEnv(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+func loginWindow() time.Duration { return durationFromEnv("LOGIN_WINDOW", 15*time.Minute) }
+
+func normalizeEmail(email string) string { return strings.ToLower(strings.TrimSpace(email)) }
+
+// Redis layout:
+//
+//	throttle:<scope>:<id>  sorted set of attempt timestamps (sliding window)
+//	lock:<scope>:<id>      present while locked out, expires when the lockout ends
+//	lockcount:<scope>:<id> number of consecutive lockouts, drives the exponential backoff
+func throttleKey(scope, id string) string  { return "throttle:" + scope + ":" + id }
+func lockKey(scope, id string) string      { return "lock:" + scope + ":" + id }
+func lockCountKey(scope, id string) string { return "lockcount:" + scope + ":" + id }
+
+// recordAttempt adds an attempt to a sliding window and returns the attempts in
+// the window along with the time until the oldest one drops out.
+func recordAttempt(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	now := time.Now()
+	var card *redis.IntCmd
+	var oldest *redis.ZSliceCmd
+
+	_, err := config.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-window).UnixNano(), 10))
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.UnixNano()), Member: primitive.NewObjectID().Hex()})
+		card = pipe.ZCard(ctx, key)
+		oldest = pipe.ZRangeWithScores(ctx, key, 0, 0)
+		pipe.Expire(ctx, key, window)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	retryAfter := window
+	if z := oldest.Val(); len(z) > 0 {
+		retryAfter = time.Unix(0, int64(z[0].Score)).Add(window).Sub(now)
+	}
+
+	return card.Val(), retryAfter, nil
+}
+
+// checkLock returns a ThrottleError while scope/id is locked out.
+func checkLock(ctx context.Context, scope, id string) error {
+	ttl, err := config.RedisClient.PTTL(ctx, lockKey(scope, id)).Result()
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		return &ThrottleError{Reason: "too many failed attempts", RetryAfter: ttl}
+	}
+	return nil
+}
+
+// lockOut locks scope/id for base * 2^(previous lockouts), capped at LOGIN_LOCKOUT_MAX.
+func lockOut(ctx context.Context, scope, id string) (time.Duration, error) {
+	count, err := config.RedisClient.Incr(ctx, lockCountKey(scope, id)).Result()
+	if err != nil {
+		return 0, err
+	}
+	config.RedisClient.Expire(ctx, lockCountKey(scope, id), 24*time.Hour)
+
+	base := durationFromEnv("LOGIN_LOCKOUT_BASE", time.Minute)
+	ceiling := durationFromEnv("LOGIN_LOCKOUT_MAX", time.Hour)
+	lockFor := base << min(count-1, 20)
+	if lockFor <= 0 || lockFor > ceiling {
+		lockFor = ceiling
+	}
+
+	if err := config.RedisClient.Set(ctx, lockKey(scope, id), 1, lockFor).Err(); err != nil {
+		return 0, err
+	}
+	// The window starts fresh once the lockout ends.
+	config.RedisClient.Del(ctx, throttleKey(scope, id))
+	throttleMetrics.Add("lockouts_"+scope, 1)
+
+	return lockFor, nil
+}
+
+// CheckLoginAllowed rejects a login attempt while the email or the IP is locked out.
+func CheckLoginAllowed(email, ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for scope, id := range map[string]string{"email": normalizeEmail(email), "ip": ip} {
+		if err := checkLock(ctx, scope, id); err != nil {
+			var te *ThrottleError
+			if errors.As(err, &te) {
+				throttleMetrics.Add("rejected_login_"+scope, 1)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordLoginFailure counts a failed login for the email and the IP, locking
+// either out once it exceeds its limit within LOGIN_WINDOW.
+func RecordLoginFailure(email, ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	throttleMetrics.Add("failed_logins", 1)
+
+	limits := []struct {
+		scope string
+		id    string
+		max   int
+	}{
+		{"email", normalizeEmail(email), intFromEnv("LOGIN_MAX_FAILURES", 5)},
+		{"ip", ip, intFromEnv("LOGIN_MAX_FAILURES_PER_IP", 20)},
+	}
+
+	// Every scope records the attempt before any lockout is reported, so rotating
+	// through locked-out emails still counts against the IP
+	var throttled *ThrottleError
+	for _, l := range limits {
+		count, _, err := recordAttempt(ctx, throttleKey(l.scope, l.id), loginWindow())
+		if err != nil {
+			return err
+		}
+		if count >= int64(l.max) {
+			lockFor, err := lockOut(ctx, l.scope, l.id)
+			if err != nil {
+				return err
+			}
+			if throttled == nil || lockFor > throttled.RetryAfter {
+				throttled = &ThrottleError{Reason: "too many failed attempts", RetryAfter: lockFor}
+			}
+		}
+	}
+
+	if throttled != nil {
+		return throttled
+	}
+	return nil
+}
+
+// RecordLoginSuccess clears the email's failure window and backoff.
+func RecordLoginSuccess(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	id := normalizeEmail(email)
+	config.RedisClient.Del(ctx, throttleKey("email", id), lockCountKey("email", id))
+}
+
+// CheckRegisterAllowed applies a per-IP sliding window to registration attempts.
+func CheckRegisterAllowed(ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	count, retryAfter, err := recordAttempt(ctx, throttleKey("register", ip), loginWindow())
+	if err != nil {
+		return err
+	}
+	if count > int64(intFromEnv("REGISTER_MAX_PER_IP", 10)) {
+		throttleMetrics.Add("rejected_register_ip", 1)
+		return &ThrottleError{Reason: "too many registration attempts", RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// CheckPasswordResetAllowed limits how often reset emails can be requested per email and IP.
+func CheckPasswordResetAllowed(email, ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	limits := []struct {
+		id  string
+		max int
+	}{
+		{"email:" + normalizeEmail(email), intFromEnv("RESET_MAX_PER_EMAIL", 3)},
+		{"ip:" + ip, intFromEnv("RESET_MAX_PER_IP", 10)},
+	}
+
+	for _, l := range limits {
+		count, retryAfter, err := recordAttempt(ctx, throttleKey("reset", l.id), loginWindow())
+		if err != nil {
+			return err
+		}
+		if count > int64(l.max) {
+			throttleMetrics.Add("rejected_password_reset", 1)
+			return &ThrottleError{Reason: "too many password reset requests", RetryAfter: retryAfter}
+		}
+	}
+	return nil
+}
+
+// UnlockLogin lifts lockouts and resets counters for an email and/or an IP.
+func UnlockLogin(email, ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var keys []string
+	if email != "" {
+		id := normalizeEmail(email)
+		keys = append(keys, throttleKey("email", id), lockKey("email", id), lockCountKey("email", id))
+	}
+	if ip != "" {
+		keys = append(keys, throttleKey("ip", ip), lockKey("ip", ip), lockCountKey("ip", ip), throttleKey("register", ip))
+	}
+	if len(keys) == 0 {
+		return errors.New("email or ip is required")
+	}
+
+	if err := config.RedisClient.Del(ctx, keys...).Err(); err != nil {
+		return err
+	}
+	throttleMetrics.Add("unlocks", 1)
+	return nil
+}
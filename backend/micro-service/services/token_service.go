@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const defaultAccessTokenTTL = 15 * time.Minute
+
+type AccessClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+func tokenSecret() ([]byte, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, errors.New("JWT_SECRET is not configured")
+	}
+	return []byte(secret), nil
+}
+
+// durationFromEnv parses a Go duration (e.g. "15m") from the env, or returns def.
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+// IssueAccessToken signs an HS256 token whose subject is the userId and whose
+// ID is the session (sid) it belongs to, so it can be revoked with the session.
+func IssueAccessToken(userId, email, sid string) (string, time.Time, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL))
+
+	claims := AccessClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userId,
+			ID:        sid,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+// ParseAccessToken verifies signature and expiry and returns the token claims.
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	var claims AccessClaims
+	_, err = jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (any, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("invalid token: missing subject")
+	}
+
+	return &claims, nil
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultTrashRetention is how long a deleted chat stays restorable when
+// TRASH_RETENTION is unset.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+var ErrChatNotInTrash = errors.New("chat not found in trash")
+
+// TrashRetention reads TRASH_RETENTION (a Go duration such as "720h").
+func TrashRetention() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("TRASH_RETENTION")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultTrashRetention
+}
+
+// GetTrashedChats lists the user's chats in the trash, most recently deleted first.
+func GetTrashedChats(userId string) ([]models.TrashedChat, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := chatCollection.Find(ctx,
+		bson.M{"userId": userId, "deletedAt": bson.M{"$exists": true}},
+		options.Find().
+			SetProjection(bson.M{"chatId": 1, "name": 1, "deletedAt": 1}).
+			SetSort(bson.D{{Key: "deletedAt", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	chats := []models.TrashedChat{}
+	if err := cursor.All(ctx, &chats); err != nil {
+		return nil, err
+	}
+	retention := TrashRetention()
+	for i := range chats {
+		chats[i].PurgeAt = chats[i].DeletedAt.Add(retention)
+	}
+	return chats, nil
+}
+
+// RestoreChat takes a chat back out of the trash.
+func RestoreChat(userId, chatId string) error {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := chatCollection.UpdateOne(ctx,
+		bson.M{"userId": userId, "chatId": chatId, "deletedAt": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deletedAt": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrChatNotInTrash
+	}
+	return nil
+}
+
+// ExpiredTrash returns up to limit chats deleted before cutoff, oldest first.
+func ExpiredTrash(cutoff time.Time, limit int64) ([]models.Chat, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := chatCollection.Find(ctx,
+		bson.M{"deletedAt": bson.M{"$lt": cutoff}},
+		options.Find().
+			SetProjection(bson.M{"userId": 1, "chatId": 1, "deletedAt": 1}).
+			SetSort(bson.D{{Key: "deletedAt", Value: 1}}).
+			SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var chats []models.Chat
+	if err := cursor.All(ctx, &chats); err != nil {
+		return nil, err
+	}
+	return chats, nil
+}
+
+// PurgeChat permanently removes a trashed chat's Redis hash, messages, share links
+// and document.
+// The chat document goes last so a failure leaves it in the trash to be retried.
+func PurgeChat(userId, chatId string) error {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := config.RedisClient.Del(ctx, fmt.Sprintf("chats:%s:%s", userId, chatId)).Err(); err != nil {
+		return fmt.Errorf("failed to delete redis key: %w", err)
+	}
+	if err := DeleteChatMessages(ctx, userId, chatId); err != nil {
+		return fmt.Errorf("failed to delete chat messages: %w", err)
+	}
+	if err := DeleteChatShareLinks(ctx, userId, chatId); err != nil {
+		return fmt.Errorf("failed to delete share links: %w", err)
+	}
+	if _, err := chatCollection.DeleteOne(ctx, bson.M{"userId": userId, "chatId": chatId, "deletedAt": bson.M{"$exists": true}}); err != nil {
+		return fmt.Errorf("failed to delete chat: %w", err)
+	}
+	return nil
+}
+
+// RemoveChatUploadDir removes UPLOAD_PATH/<userId>/<chatId>, unless forks of the
+// chat still share files stored there; those are left for the AI service to remove
+// with their last reference.
+func RemoveChatUploadDir(userId, chatId string) error {
+	basePath := os.Getenv("UPLOAD_PATH")
+	if basePath == "" || userId == "" || chatId == "" || filepath.Base(userId) != userId || filepath.Base(chatId) != chatId {
+		return fmt.Errorf("refusing to remove upload dir for userId=%q chatId=%q", userId, chatId)
+	}
+
+	fileCollection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	shared, err := fileCollection.CountDocuments(ctx,
+		bson.M{"sourceUserId": userId, "sourceChatId": chatId},
+		options.Count().SetLimit(1),
+	)
+	if err != nil {
+		return err
+	}
+	if shared > 0 {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(basePath, userId, chatId))
+}
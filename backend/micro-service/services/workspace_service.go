@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	config "github.com/Recker-Dev/NextJs-GPT/backend/micro-service/config"
+	"github.com/Recker-Dev/NextJs-GPT/backend/micro-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrChatAccessDenied is returned when the requester may not touch a chat. It reads
+// the same as a missing chat so chat ids of other users are not disclosed.
+var ErrChatAccessDenied = errors.New("user or chat not found")
+
+// ErrInsufficientRole is returned when the requester can see a chat but not perform the action.
+var ErrInsufficientRole = errors.New("your role on this chat does not allow this action")
+
+var roleRank = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+// RoleAtLeast reports whether role grants at least the permissions of min.
+func RoleAtLeast(role, min string) bool {
+	return roleRank[role] >= roleRank[min]
+}
+
+// ChatAccess describes how a requester reaches a chat. OwnerId is the userId the
+// chat data (messages, uploads, Redis key) is stored under.
+type ChatAccess struct {
+	OwnerId     string
+	WorkspaceId string
+	Role        string
+}
+
+// AuthorizeChat resolves the requester's role on chatId and checks it is at least minRole.
+func AuthorizeChat(requesterId, chatId, minRole string) (*ChatAccess, error) {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOne().SetProjection(bson.M{"userId": 1, "workspaceId": 1})
+
+	var chat models.Chat
+	// Trashed chats are only reachable through the trash routes
+	if err := chatCollection.FindOne(ctx, bson.M{"chatId": chatId, "deletedAt": bson.M{"$exists": false}}, opts).Decode(&chat); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrChatAccessDenied
+		}
+		return nil, err
+	}
+
+	access := &ChatAccess{OwnerId: chat.UserId, WorkspaceId: chat.WorkspaceId}
+
+	switch {
+	case chat.UserId == requesterId:
+		access.Role = models.RoleOwner
+	case chat.WorkspaceId != "":
+		role, err := WorkspaceRole(chat.WorkspaceId, requesterId)
+		if err != nil {
+			return nil, err
+		}
+		access.Role = role
+	}
+
+	if access.Role == "" {
+		return nil, ErrChatAccessDenied
+	}
+	if !RoleAtLeast(access.Role, minRole) {
+		return nil, ErrInsufficientRole
+	}
+
+	return access, nil
+}
+
+// WorkspaceRole returns the member's role, or "" if they are not a member.
+func WorkspaceRole(workspaceId, userId string) (string, error) {
+	ws, err := getWorkspace(workspaceId)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	for _, m := range ws.Members {
+		if m.UserId == userId {
+			return m.Role, nil
+		}
+	}
+	return "", nil
+}
+
+// MemberWorkspaceIds lists the workspaces userId belongs to.
+func MemberWorkspaceIds(userId string) ([]string, error) {
+	workspaces, err := ListWorkspaces(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(workspaces))
+	for _, ws := range workspaces {
+		ids = append(ids, ws.ID.Hex())
+	}
+	return ids, nil
+}
+
+func getWorkspace(workspaceId string) (*models.Workspace, error) {
+	objID, err := primitive.ObjectIDFromHex(workspaceId)
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	ws, err := FindExactlyOne[models.Workspace](os.Getenv("WORKSPACE_COLLECTION"), bson.M{"_id": objID})
+	if err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+// requireWorkspaceRole loads the workspace and checks the requester's role in it.
+func requireWorkspaceRole(workspaceId, requesterId, minRole string) (*models.Workspace, error) {
+	ws, err := getWorkspace(workspaceId)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("workspace not found")
+		}
+		return nil, err
+	}
+
+	for _, m := range ws.Members {
+		if m.UserId == requesterId {
+			if !RoleAtLeast(m.Role, minRole) {
+				return nil, fmt.Errorf("workspace role %s required", minRole)
+			}
+			return ws, nil
+		}
+	}
+	return nil, errors.New("workspace not found")
+}
+
+func CreateWorkspace(ownerId, name string) (*models.Workspace, error) {
+	workspaceCollection := config.GetCollection(os.Getenv("WORKSPACE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	ws := models.Workspace{
+		ID:      primitive.NewObjectID(),
+		Name:    name,
+		OwnerId: ownerId,
+		Members: []models.WorkspaceMember{
+			{UserId: ownerId, Role: models.RoleOwner, AddedAt: now},
+		},
+		CreatedAt: now,
+	}
+
+	if _, err := workspaceCollection.InsertOne(ctx, ws); err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+func ListWorkspaces(userId string) ([]models.Workspace, error) {
+	workspaceCollection := config.GetCollection(os.Getenv("WORKSPACE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := workspaceCollection.Find(ctx, bson.M{"members.userId": userId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	workspaces := []models.Workspace{}
+	if err := cursor.All(ctx, &workspaces); err != nil {
+		return nil, err
+	}
+	return workspaces, nil
+}
+
+// SetWorkspaceMember adds the user registered under email, or changes their role.
+func SetWorkspaceMember(requesterId, workspaceId, email, role string) (*models.WorkspaceMember, error) {
+	if role != models.RoleEditor && role != models.RoleViewer {
+		return nil, errors.New("role must be editor or viewer")
+	}
+
+	ws, err := requireWorkspaceRole(workspaceId, requesterId, models.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := FindExactlyOne[models.User](os.Getenv("AUTH_COLLECTION"), bson.M{"email": email})
+	if err != nil {
+		return nil, errors.New("no user registered with that email")
+	}
+	memberId := user.ID.Hex()
+	if memberId == ws.OwnerId {
+		return nil, errors.New("the workspace owner's role cannot be changed")
+	}
+
+	workspaceCollection := config.GetCollection(os.Getenv("WORKSPACE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	member := models.WorkspaceMember{UserId: memberId, Role: role, AddedAt: time.Now().UTC()}
+
+	// Try updating an existing membership first, then append a new one.
+	res, err := workspaceCollection.UpdateOne(ctx,
+		bson.M{"_id": ws.ID, "members.userId": memberId},
+		bson.M{"$set": bson.M{"members.$.role": role}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		_, err = workspaceCollection.UpdateOne(ctx,
+			bson.M{"_id": ws.ID, "members.userId": bson.M{"$ne": memberId}},
+			bson.M{"$push": bson.M{"members": member}},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &member, nil
+}
+
+// RemoveWorkspaceMember lets the owner remove anyone but themselves, and members leave.
+func RemoveWorkspaceMember(requesterId, workspaceId, memberId string) error {
+	minRole := models.RoleOwner
+	if requesterId == memberId {
+		minRole = models.RoleViewer
+	}
+
+	ws, err := requireWorkspaceRole(workspaceId, requesterId, minRole)
+	if err != nil {
+		return err
+	}
+	if memberId == ws.OwnerId {
+		return errors.New("the workspace owner cannot be removed; delete the workspace instead")
+	}
+
+	workspaceCollection := config.GetCollection(os.Getenv("WORKSPACE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := workspaceCollection.UpdateOne(ctx,
+		bson.M{"_id": ws.ID},
+		bson.M{"$pull": bson.M{"members": bson.M{"userId": memberId}}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return errors.New("user is not a member of this workspace")
+	}
+	return nil
+}
+
+// AddChatToWorkspace moves a chat the requester created into a workspace they can edit.
+func AddChatToWorkspace(requesterId, workspaceId, chatId string) error {
+	if _, err := requireWorkspaceRole(workspaceId, requesterId, models.RoleEditor); err != nil {
+		return err
+	}
+	return setChatWorkspace(requesterId, chatId, workspaceId)
+}
+
+// RemoveChatFromWorkspace makes a chat private to its creator again.
+func RemoveChatFromWorkspace(requesterId, workspaceId, chatId string) error {
+	access, err := AuthorizeChat(requesterId, chatId, models.RoleViewer)
+	if err != nil {
+		return err
+	}
+	if access.WorkspaceId != workspaceId {
+		return errors.New("chat is not in this workspace")
+	}
+
+	// The chat creator or the workspace owner may take a chat out.
+	if access.OwnerId != requesterId {
+		if _, err := requireWorkspaceRole(workspaceId, requesterId, models.RoleOwner); err != nil {
+			return err
+		}
+	}
+	return setChatWorkspace(access.OwnerId, chatId, "")
+}
+
+func setChatWorkspace(ownerId, chatId, workspaceId string) error {
+	chatCollection := config.GetCollection(os.Getenv("CHAT_COLLECTION"))
+	fileCollection := config.GetCollection(os.Getenv("FILE_COLLECTION"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"workspaceId": workspaceId}}
+	if workspaceId == "" {
+		update = bson.M{"$unset": bson.M{"workspaceId": ""}}
+	}
+
+	res, err := chatCollection.UpdateOne(ctx, bson.M{"userId": ownerId, "chatId": chatId}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrChatAccessDenied
+	}
+
+	_, err = fileCollection.UpdateMany(ctx, bson.M{"userId": ownerId, "chatId": chatId}, update)
+	return err
+}
+
+// DeleteWorkspace removes the workspace; its chats fall back to their creators.
+func DeleteWorkspace(requesterId, workspaceId string) error {
+	ws, err := requireWorkspaceRole(workspaceId, requesterId, models.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	return detachWorkspace(ws.ID)
+}
+
+func detachWorkspace(workspaceId primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	unset := bson.M{"$unset": bson.M{"workspaceId": ""}}
+	filter := bson.M{"workspaceId": workspaceId.Hex()}
+
+	if _, err := config.GetCollection(os.Getenv("CHAT_COLLECTION")).UpdateMany(ctx, filter, unset); err != nil {
+		return err
+	}
+	if _, err := config.GetCollection(os.Getenv("FILE_COLLECTION")).UpdateMany(ctx, filter, unset); err != nil {
+		return err
+	}
+
+	_, err := config.GetCollection(os.Getenv("WORKSPACE_COLLECTION")).DeleteOne(ctx, bson.M{"_id": workspaceId})
+	return err
+}
+
+// LeaveAllWorkspaces is used by account deletion: owned workspaces are deleted,
+// memberships elsewhere are dropped.
+func LeaveAllWorkspaces(userId string) error {
+	workspaces, err := ListWorkspaces(userId)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	workspaceCollection := config.GetCollection(os.Getenv("WORKSPACE_COLLECTION"))
+	for _, ws := range workspaces {
+		if ws.OwnerId == userId {
+			if err := detachWorkspace(ws.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := workspaceCollection.UpdateOne(ctx,
+			bson.M{"_id": ws.ID},
+			bson.M{"$pull": bson.M{"members": bson.M{"userId": userId}}},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,10 +2,14 @@ module github.com/Recker-Dev/NextJs-GPT/backend/ws-micro-service
 
 go 1.24.5
 
-require github.com/gorilla/websocket v1.5.3
+require (
+	github.com/IBM/sarama v1.45.2
+	github.com/golang-jwt/jwt/v5 v5.3.0
+	github.com/gorilla/websocket v1.5.3
+	github.com/joho/godotenv v1.5.1
+)
 
 require (
-	github.com/IBM/sarama v1.45.2 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/eapache/go-resiliency v1.7.0 // indirect
 	github.com/eapache/go-xerial-snappy v0.0.0-20230731223053-c322873962e3 // indirect
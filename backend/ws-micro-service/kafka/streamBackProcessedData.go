@@ -14,7 +14,7 @@ import (
 func StartKafkaToUserRoutine(brokers []string,
 	replyTopic, groupId string,
 	hub interface {
-		GetClient(userId, chatId string) types.TriggerWritePump
+		GetAudience(ownerId, chatId string) types.TriggerWritePump
 	},
 ) {
 	config := sarama.NewConfig()
@@ -38,7 +38,7 @@ func StartKafkaToUserRoutine(brokers []string,
 
 type consumerHandler struct {
 	hub interface {
-		GetClient(userId, chatId string) types.TriggerWritePump
+		GetAudience(ownerId, chatId string) types.TriggerWritePump
 	}
 }
 
@@ -59,9 +59,10 @@ func (h *consumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim s
 		userId, chatId := parts[0], parts[1]
 
 		// no need to unmarshal for routing unless you want validation
-		client := h.hub.GetClient(userId, chatId)
-		if client != nil {
-			client.SendToWritePump(msg.Value) // forward raw payload
+		// fans out to every member currently viewing the chat
+		viewers := h.hub.GetAudience(userId, chatId)
+		if viewers != nil {
+			viewers.SendToWritePump(msg.Value) // forward raw payload
 		} else {
 			log.Printf("⚠️ No client found for user=%s chat=%s", userId, chatId)
 		}
@@ -6,8 +6,17 @@ import (
 
 	"github.com/Recker-Dev/NextJs-GPT/backend/ws-micro-service/kafka"
 	"github.com/Recker-Dev/NextJs-GPT/backend/ws-micro-service/ws"
+	"github.com/joho/godotenv"
 )
 
+func init() {
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+	log.Println("✅ Env Loaded.")
+}
+
 func main() {
 
 	brokers := []string{"localhost:9092"}
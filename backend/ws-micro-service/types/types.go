@@ -21,15 +21,33 @@ type TriggerWritePump interface {
 	SendToWritePump(msg interface{})
 }
 
+// Actions an IncomingQuery can carry; an empty action means QuerySend.
+const (
+	QuerySend       = "send"       // new user message, answered by the AI
+	QueryEdit       = "edit"       // new variant of TargetId's user message, answered by the AI
+	QueryRegenerate = "regenerate" // another AI reply to TargetId (a user message or one of its replies)
+	QuerySelect     = "select"     // make TargetId the active variant among its siblings
+)
+
 type IncomingQuery struct {
 	MsgId     string    `json:"msgId" bson:"msgId"` // unique per message
 	ChatId    string    `json:"chatId" bson:"chatId"`
 	UserId    string    `json:"userId" bson:"userId"`
-	Role      string    `json:"role" bson:"role"`                           // "user"
-	Content   string    `json:"content" bson:"content"`                     // full text prompt
-	FileIds   []string  `json:"fileIds,omitempty" bson:"fileIds,omitempty"` // optional
-	MemIds    []string  `json:"memIds,omitempty" bson:"memIds,omitempty"`   // optional
-	Timestamp time.Time `json:"timestamp" bson:"timestamp"`                 // unix epoch ms
+	Action    string    `json:"action,omitempty" bson:"action,omitempty"`     // see Query* constants
+	TargetId  string    `json:"targetId,omitempty" bson:"targetId,omitempty"` // message acted on by edit/regenerate/select
+	Role      string    `json:"role" bson:"role"`                             // "user"
+	Content   string    `json:"content" bson:"content"`                       // full text prompt; send/edit only
+	FileIds   []string  `json:"fileIds,omitempty" bson:"fileIds,omitempty"`   // optional
+	MemIds    []string  `json:"memIds,omitempty" bson:"memIds,omitempty"`     // optional
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`                   // unix epoch ms
+}
+
+// ErrorFrame is sent back to the socket when an incoming frame is rejected.
+type ErrorFrame struct {
+	Type    string `json:"type"` // always "error"
+	Code    string `json:"code"` // e.g. "invalid_json", "invalid_frame", "identity_mismatch"
+	Message string `json:"message"`
+	MsgId   string `json:"msgId,omitempty"`
 }
 
 // Kept types for future reference
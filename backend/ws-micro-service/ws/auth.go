@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Browsers cannot set headers on a WebSocket handshake, so the token may also be
+// passed as the second entry of Sec-WebSocket-Protocol: ["access_token", <token>].
+const tokenSubprotocol = "access_token"
+
+// API keys minted by the micro-service; they need the "query" scope to open a socket.
+const apiKeyPrefix = "mlk_"
+
+var chatServiceClient = &http.Client{Timeout: 5 * time.Second}
+
+// extractToken returns the bearer token from the "token" query param or the subprotocol header.
+func extractToken(r *http.Request) (token string, viaSubprotocol bool) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token, false
+	}
+
+	protocols := requestedSubprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == tokenSubprotocol {
+			return protocols[i+1], true
+		}
+	}
+	return "", false
+}
+
+func requestedSubprotocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(header, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				protocols = append(protocols, p)
+			}
+		}
+	}
+	return protocols
+}
+
+// verifyToken checks the HS256 signature and expiry and returns the token subject (userId).
+func verifyToken(tokenString string) (string, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return "", errors.New("JWT_SECRET is not configured")
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (any, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
+	if claims.Subject == "" {
+		return "", errors.New("invalid token: missing subject")
+	}
+
+	return claims.Subject, nil
+}
+
+// chatAccess is the micro-service's answer: the userId the chat is stored under
+// (whose key routes Kafka traffic) and the requester's role on it.
+type chatAccess struct {
+	OwnerId string `json:"ownerId"`
+	Role    string `json:"role"`
+}
+
+// verifyChatAccess asks the chat micro-service whether userId may open chatId, either
+// as its creator or as a workspace member. The bearer token is forwarded so the
+// micro-service also applies session revocation.
+func verifyChatAccess(ctx context.Context, token, userId, chatId string) (*chatAccess, error) {
+	base := os.Getenv("CHAT_SERVICE_URL")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+
+	endpoint := fmt.Sprintf("%s/chatAccess/%s/%s", strings.TrimRight(base, "/"), url.PathEscape(userId), url.PathEscape(chatId))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := chatServiceClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("chat access lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return nil, errors.New("chat not found for this user")
+	default:
+		return nil, fmt.Errorf("chat access lookup returned status %d", resp.StatusCode)
+	}
+
+	var access chatAccess
+	if err := json.NewDecoder(resp.Body).Decode(&access); err != nil {
+		return nil, fmt.Errorf("chat access lookup returned invalid body: %w", err)
+	}
+	if access.OwnerId == "" {
+		return nil, errors.New("chat access lookup returned no ownerId")
+	}
+	return &access, nil
+}
+
+// allowedOrigins reads WS_ALLOWED_ORIGINS (comma separated), defaulting to the local frontend.
+func allowedOrigins() map[string]bool {
+	raw := os.Getenv("WS_ALLOWED_ORIGINS")
+	if raw == "" {
+		raw = "http://localhost:3100"
+	}
+
+	origins := make(map[string]bool)
+	for _, o := range strings.Split(raw, ",") {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			origins[o] = true
+		}
+	}
+	return origins
+}
+
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Non-browser clients do not send an Origin; they still need a valid token.
+		return true
+	}
+
+	origins := allowedOrigins()
+	return origins["*"] || origins[strings.TrimRight(origin, "/")]
+}
@@ -2,12 +2,10 @@ package ws
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
+	"sync"
 	"time"
 
-	"github.com/Recker-Dev/NextJs-GPT/backend/ws-micro-service/types"
-
 	"github.com/gorilla/websocket"
 )
 
@@ -21,32 +19,29 @@ const (
 // Client represents a WebSocket connection.
 type Client struct {
 	Hub          *Hub
-	UserId       string
+	UserId       string // authenticated viewer
+	OwnerId      string // userId the chat is stored under; differs from UserId for shared chats
 	ChatId       string
+	Role         string // "owner", "editor" or "viewer"
 	CurrentMsgId string
 	Conn         *websocket.Conn
 	Send         chan any
+
+	// sendMu guards closing Send: the hub closes it while Kafka consumers and
+	// readPump may still be sending from an audience snapshot.
+	sendMu     sync.Mutex
+	sendClosed bool
 }
 
 // NewClient constructs a new Client.
-func NewClient(hub *Hub, userId, chatId string, conn *websocket.Conn) *Client {
-	return &Client{Hub: hub, UserId: userId, ChatId: chatId, Conn: conn, Send: make(chan any, 256)}
+func NewClient(hub *Hub, userId, ownerId, chatId, role string, conn *websocket.Conn) *Client {
+	return &Client{Hub: hub, UserId: userId, OwnerId: ownerId, ChatId: chatId, Role: role, Conn: conn, Send: make(chan any, 256)}
 }
 
 // readPump reads incoming WebSocket messages and delegates to Hub.Publish.
 func (c *Client) readPump() {
 	defer func() {
-		type dbRequest struct {
-			UserId string `json:"userId"`
-			ChatId string `json:"chatId"`
-			Action string `json:"action"` // "flush" or "del"
-		}
-		data, _ := json.Marshal(dbRequest{UserId: c.UserId, ChatId: c.ChatId, Action: "del"})
-		// Key is in dbops:userId:chatId format
-		if err := c.Hub.Publisher.SendMessage("db_ops", fmt.Sprintf("dbops:%s:%s", c.UserId, c.ChatId), data); err != nil {
-			log.Printf("[WS] Kafka publish failed for signal: %v", err)
-		}
-		log.Printf("✅ Raised Redis key delete for UserId: %s and ChatId: %s", c.UserId, c.ChatId)
+		// The hub raises the flush-and-delete if this was the chat's last viewer
 		c.Hub.Unregister <- c
 		c.Conn.Close()
 	}()
@@ -65,19 +60,29 @@ func (c *Client) readPump() {
 
 		if msgType != websocket.TextMessage {
 			log.Printf("⚠️ Ignoring non-text message for %s_%s", c.UserId, c.ChatId)
+			c.sendError("unsupported_message", "only text frames are accepted", "")
+			continue
+		}
+
+		incoming, fe := c.parseIncomingQuery(msg)
+		if fe != nil {
+			log.Printf("❌ Rejected frame for %s_%s: %v", c.UserId, c.ChatId, fe)
+			c.sendError(fe.Code, fe.Message, fe.MsgId)
 			continue
 		}
 
-		var incoming types.IncomingQuery
-		if err := json.Unmarshal(msg, &incoming); err != nil {
-			log.Printf("❌ Unmarshal failed for %s_%s: %v", c.UserId, c.ChatId, err)
+		data, err := json.Marshal(incoming)
+		if err != nil {
+			log.Printf("❌ Marshal failed for %s_%s: %v", c.UserId, c.ChatId, err)
+			c.sendError("internal_error", "failed to encode query", incoming.MsgId)
 			continue
 		}
 
-		key := incoming.UserId + "_" + incoming.ChatId // Key is in userId_chatId format
+		key := c.OwnerId + "_" + c.ChatId // Key is in userId_chatId format
 
-		if err := c.Hub.Publisher.SendMessage("user_query", key, msg); err != nil {
+		if err := c.Hub.Publisher.SendMessage("user_query", key, data); err != nil {
 			log.Printf("❌ Kafka publish failed: %v", err)
+			c.sendError("publish_failed", "query could not be queued, please retry", incoming.MsgId)
 		}
 
 	}
@@ -115,9 +120,24 @@ func (c *Client) writePump() {
 }
 
 func (c *Client) SendToWritePump(msg interface{}) {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.sendClosed {
+		return
+	}
 	select {
 	case c.Send <- msg:
 	default:
 		log.Printf("Send buffer full for %s_%s — dropping message", c.UserId, c.ChatId)
 	}
 }
+
+// closeSend closes Send once, stopping writePump; later sends are dropped.
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if !c.sendClosed {
+		c.sendClosed = true
+		close(c.Send)
+	}
+}
@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Recker-Dev/NextJs-GPT/backend/ws-micro-service/types"
+)
+
+const (
+	maxQueryContentLength = 32 * 1024
+	maxAttachedIds        = 50
+	maxIdLength           = 128
+)
+
+// frameError carries the code reported back to the client in an ErrorFrame.
+type frameError struct {
+	Code    string
+	Message string
+	MsgId   string
+}
+
+func (e *frameError) Error() string { return e.Code + ": " + e.Message }
+
+// parseIncomingQuery decodes a frame into an IncomingQuery, enforces its schema
+// and pins its identity to the one the client was registered with.
+func (c *Client) parseIncomingQuery(raw []byte) (*types.IncomingQuery, *frameError) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	var q types.IncomingQuery
+	if err := dec.Decode(&q); err != nil {
+		return nil, &frameError{Code: "invalid_json", Message: err.Error()}
+	}
+
+	if c.Role == "viewer" {
+		return nil, &frameError{Code: "forbidden", Message: "viewers cannot send queries to this chat", MsgId: q.MsgId}
+	}
+
+	// Clients may address the chat by their own id or by its owner's; downstream
+	// services always get the owner's, which is what the chat is stored under.
+	if (q.UserId != "" && q.UserId != c.UserId && q.UserId != c.OwnerId) || (q.ChatId != "" && q.ChatId != c.ChatId) {
+		return nil, &frameError{Code: "identity_mismatch", Message: "userId/chatId do not match this connection", MsgId: q.MsgId}
+	}
+	q.UserId = c.OwnerId
+	q.ChatId = c.ChatId
+
+	if q.Action == "" {
+		q.Action = types.QuerySend
+	}
+	// Only send and edit carry a new user message
+	needsContent := q.Action == types.QuerySend || q.Action == types.QueryEdit
+
+	switch {
+	case q.MsgId == "":
+		return nil, &frameError{Code: "invalid_frame", Message: "msgId is required"}
+	case len(q.MsgId) > maxIdLength:
+		return nil, &frameError{Code: "invalid_frame", Message: "msgId is too long"}
+	case q.Action != types.QuerySend && q.Action != types.QueryEdit && q.Action != types.QueryRegenerate && q.Action != types.QuerySelect:
+		return nil, &frameError{Code: "invalid_frame", Message: fmt.Sprintf("unsupported action %q", q.Action), MsgId: q.MsgId}
+	case q.Action == types.QuerySend && q.TargetId != "":
+		return nil, &frameError{Code: "invalid_frame", Message: "targetId is only valid for edit, regenerate and select", MsgId: q.MsgId}
+	case q.Action != types.QuerySend && q.TargetId == "":
+		return nil, &frameError{Code: "invalid_frame", Message: "targetId is required for " + q.Action, MsgId: q.MsgId}
+	case q.Action != types.QuerySend && len(q.TargetId) > maxIdLength:
+		return nil, &frameError{Code: "invalid_frame", Message: "targetId is too long", MsgId: q.MsgId}
+	case q.Role != "" && q.Role != "user":
+		return nil, &frameError{Code: "invalid_frame", Message: fmt.Sprintf("unsupported role %q", q.Role), MsgId: q.MsgId}
+	case needsContent && q.Content == "":
+		return nil, &frameError{Code: "invalid_frame", Message: "content is required", MsgId: q.MsgId}
+	case !needsContent && q.Content != "":
+		return nil, &frameError{Code: "invalid_frame", Message: "content is only valid for send and edit", MsgId: q.MsgId}
+	case !utf8.ValidString(q.Content) || len(q.Content) > maxQueryContentLength:
+		return nil, &frameError{Code: "invalid_frame", Message: fmt.Sprintf("content must be valid UTF-8 and at most %d bytes", maxQueryContentLength), MsgId: q.MsgId}
+	case len(q.FileIds) > maxAttachedIds || len(q.MemIds) > maxAttachedIds:
+		return nil, &frameError{Code: "invalid_frame", Message: fmt.Sprintf("at most %d fileIds and memIds are allowed", maxAttachedIds), MsgId: q.MsgId}
+	}
+
+	q.Role = "user"
+	if q.Timestamp.IsZero() {
+		q.Timestamp = time.Now().UTC()
+	}
+
+	return &q, nil
+}
+
+// sendError queues a typed error frame for the client.
+func (c *Client) sendError(code, message, msgId string) {
+	data, err := json.Marshal(types.ErrorFrame{Type: "error", Code: code, Message: message, MsgId: msgId})
+	if err != nil {
+		return
+	}
+	c.SendToWritePump(data)
+}
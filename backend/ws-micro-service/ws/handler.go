@@ -1,37 +1,81 @@
 package ws
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins for development.
-		return true
-	},
+	CheckOrigin: checkOrigin,
 }
 
 func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	// Extract UserId and ChatId from query parameters,
-	userId := r.URL.Query().Get("userId")
 	chatId := r.URL.Query().Get("chatId")
+	if chatId == "" {
+		http.Error(w, "ChatId is required", http.StatusBadRequest)
+		return
+	}
 
-	if userId == "" || chatId == "" {
-		http.Error(w, "UserId and ChatId are required", http.StatusBadRequest)
+	if !checkOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	token, viaSubprotocol := extractToken(r)
+	if token == "" {
+		http.Error(w, "Access token is required", http.StatusUnauthorized)
+		return
+	}
+
+	// The identity comes from the token; a query-string userId must agree with it.
+	// API keys are opaque here, so their owner is checked by the access lookup below.
+	queryUserId := r.URL.Query().Get("userId")
+	userId := queryUserId
+	if strings.HasPrefix(token, apiKeyPrefix) {
+		if userId == "" {
+			http.Error(w, "UserId is required when using an API key", http.StatusBadRequest)
+			return
+		}
+	} else {
+		subject, err := verifyToken(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if queryUserId != "" && queryUserId != subject {
+			http.Error(w, "UserId does not match access token", http.StatusForbidden)
+			return
+		}
+		userId = subject
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	access, err := verifyChatAccess(ctx, token, userId, chatId)
+	if err != nil {
+		log.Printf("[WS] Rejected %s_%s: %v", userId, chatId, err)
+		http.Error(w, "Chat not accessible", http.StatusForbidden)
+		return
+	}
+
+	var responseHeader http.Header
+	if viaSubprotocol {
+		responseHeader = http.Header{"Sec-WebSocket-Protocol": {tokenSubprotocol}}
+	}
+
+	conn, err := upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
 	// 👇 Create client with initialized Send channel
-	client := NewClient(hub, userId, chatId, conn)
+	client := NewClient(hub, userId, access.OwnerId, chatId, access.Role, conn)
 
 	hub.Register <- client // Registers the client with the hub
 
@@ -1,15 +1,20 @@
 package ws
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
 	"sync"
+
 	"github.com/Recker-Dev/NextJs-GPT/backend/ws-micro-service/kafka"
 	"github.com/Recker-Dev/NextJs-GPT/backend/ws-micro-service/types"
 )
 
 // Hub maintains active WebSocket clients and broadcasts messages.
 type Hub struct {
-	Connections map[string]map[string]*Client
+	// ownerId -> chatId -> viewer userId -> client. Kafka traffic is keyed by the
+	// chat's ownerId, so every member viewing a shared chat sits under one entry.
+	Connections map[string]map[string]map[string]*Client
 	Register    chan *Client
 	Unregister  chan *Client
 
@@ -21,7 +26,7 @@ type Hub struct {
 // publisher func(topic, key string, data []byte
 func NewHub(publisher *kafka.PublisherHandler) *Hub {
 	return &Hub{
-		Connections: make(map[string]map[string]*Client),
+		Connections: make(map[string]map[string]map[string]*Client),
 		Register:    make(chan *Client),
 		Unregister:  make(chan *Client),
 
@@ -35,40 +40,92 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.Register:
 			h.Mu.Lock()
-			if h.Connections[client.UserId] == nil {
-				h.Connections[client.UserId] = make(map[string]*Client)
+			if h.Connections[client.OwnerId] == nil {
+				h.Connections[client.OwnerId] = make(map[string]map[string]*Client)
+			}
+			viewers := h.Connections[client.OwnerId][client.ChatId]
+			if viewers == nil {
+				viewers = make(map[string]*Client)
+				h.Connections[client.OwnerId][client.ChatId] = viewers
 			}
-			// Close old connection if exists
-			if old, ok := h.Connections[client.UserId][client.ChatId]; ok {
+			// Close old connection of the same viewer if exists
+			if old, ok := viewers[client.UserId]; ok {
+				old.closeSend()
 				old.Conn.Close()
 			}
-			h.Connections[client.UserId][client.ChatId] = client
-			log.Printf("Registered client %s_%s", client.UserId, client.ChatId)
+			viewers[client.UserId] = client
+			log.Printf("Registered client %s on %s_%s", client.UserId, client.OwnerId, client.ChatId)
 			h.Mu.Unlock()
 
 		case client := <-h.Unregister:
+			lastViewer := false
 			h.Mu.Lock()
-			if chats, ok := h.Connections[client.UserId]; ok {
-				if _, ok2 := chats[client.ChatId]; ok2 {
-					delete(chats, client.ChatId)
-					close(client.Send)
-					log.Printf("Unregistered client %s_%s", client.UserId, client.ChatId)
+			if chats, ok := h.Connections[client.OwnerId]; ok {
+				viewers := chats[client.ChatId]
+				// Only drop the entry if it was not already replaced by a newer connection
+				if current, ok2 := viewers[client.UserId]; ok2 && current == client {
+					delete(viewers, client.UserId)
+					client.closeSend()
+					log.Printf("Unregistered client %s on %s_%s", client.UserId, client.OwnerId, client.ChatId)
+					if len(viewers) == 0 {
+						delete(chats, client.ChatId)
+						lastViewer = true
+					}
 					if len(chats) == 0 {
-						delete(h.Connections, client.UserId)
+						delete(h.Connections, client.OwnerId)
 					}
 				}
 			}
 			h.Mu.Unlock()
+
+			// Decided under the lock, so concurrent leaves raise it exactly once and a
+			// reconnect that replaced this client keeps the chat live
+			if lastViewer {
+				h.raiseChatDelete(client.OwnerId, client.ChatId)
+			}
 		}
 	}
 }
 
+// raiseChatDelete asks the micro-service to flush a chat nobody is viewing any more
+// and drop its Redis key.
+func (h *Hub) raiseChatDelete(ownerId, chatId string) {
+	type dbRequest struct {
+		UserId string `json:"userId"`
+		ChatId string `json:"chatId"`
+		Action string `json:"action"` // "flush" or "del"
+	}
+	data, _ := json.Marshal(dbRequest{UserId: ownerId, ChatId: chatId, Action: "del"})
+	// Key is in dbops:userId:chatId format
+	if err := h.Publisher.SendMessage("db_ops", fmt.Sprintf("dbops:%s:%s", ownerId, chatId), data); err != nil {
+		log.Printf("[WS] Kafka publish failed for signal: %v", err)
+		return
+	}
+	log.Printf("✅ Raised Redis key delete for UserId: %s and ChatId: %s", ownerId, chatId)
+}
 
-func (h *Hub) GetClient(userId, chatId string) types.TriggerWritePump {
+// audience fans a payload out to every client viewing a chat.
+type audience []*Client
+
+func (a audience) SendToWritePump(msg interface{}) {
+	for _, c := range a {
+		c.SendToWritePump(msg)
+	}
+}
+
+// GetAudience returns the clients viewing ownerId's chatId, or nil if nobody is.
+func (h *Hub) GetAudience(ownerId, chatId string) types.TriggerWritePump {
 	h.Mu.Lock()
 	defer h.Mu.Unlock()
-	if chats, ok := h.Connections[userId]; ok {
-		return chats[chatId] // *Client, which implements SendToWritePump
+
+	viewers := h.Connections[ownerId][chatId]
+	if len(viewers) == 0 {
+		return nil
+	}
+
+	a := make(audience, 0, len(viewers))
+	for _, c := range viewers {
+		a = append(a, c)
 	}
-	return nil
+	return a
 }